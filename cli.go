@@ -0,0 +1,410 @@
+package gormlib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Main exécute l'interface en ligne de commande de gormlib avec les migrations
+// du registre fourni (le registre global si nil).
+//
+// Le binaire gormlib précompilé ne peut pas importer les migrations d'un
+// projet. Pour les exécuter, construisez votre propre binaire qui importe le
+// paquet des migrations (dont le fichier registry_gen.go enregistre chaque
+// migration) puis appelle Main :
+//
+//	package main
+//
+//	import (
+//		"github.com/urmaps/z-gormlib"
+//
+//		_ "example.com/myapp/migrations"
+//	)
+//
+//	func main() {
+//		gormlib.Main(gormlib.GlobalRegistry())
+//	}
+//
+// Sans le dossier -dir, par exemple dans une image qui ne contient pas les
+// sources, les migrations du registre sont exécutées dans l'ordre de leur nom.
+//
+// -autogen compare au schéma de la base les modèles enregistrés par
+// RegisterGlobalModel (ou RegisterModel sur le registre fourni) avant Main.
+func Main(registry *MigrationRegistry) {
+	// Charger les variables d'environnement
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: .env file not found")
+	}
+
+	if registry == nil {
+		registry = globalRegistry
+	}
+
+	// Définir les flags
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	createMigration := flags.String("create-migration", "", "Create a new migration with the specified name")
+	sqlMigration := flags.Bool("sql", false, "Avec -create-migration, crée une paire de fichiers .up.sql / .down.sql")
+	migrate := flags.Bool("migrate", false, "Exécute les migrations en attente")
+	rollback := flags.Bool("rollback", false, "Annule la dernière migration")
+	target := flags.String("to", "", "Applique ou annule les migrations jusqu'à la migration indiquée")
+	steps := flags.Int("steps", 0, "Annule les N dernières migrations appliquées")
+	rollbackAll := flags.Bool("rollback-all", false, "Annule toutes les migrations appliquées")
+	status := flags.Bool("status", false, "Affiche l'état des migrations (appliquées, en attente, manquantes, hors ordre)")
+	format := flags.String("format", "table", "Format de sortie de -status: table ou json")
+	repair := flags.Bool("repair", false, "Réenregistre les sommes de contrôle des migrations déjà appliquées")
+	forceUnlock := flags.Bool("force-unlock", false, "Libère un verrou de migration bloqué en terminant la session qui le détient")
+	dryRun := flags.Bool("dry-run", false, "Avec -migrate ou -rollback, affiche le SQL sans l'exécuter")
+	lockTimeout := flags.Duration("lock-timeout", 0, "Délai maximum d'attente du verrou de migration (défaut: 1m)")
+	autogen := flags.String("autogen", "", "Crée une migration nommée d'après la différence entre les modèles enregistrés et le schéma de la base")
+	dumpSchema := flags.String("dump-schema", "", "Réécrit ce fichier avec le DDL du schéma après -migrate, -rollback, -to, -steps ou -rollback-all, ou seul pour l'écrire immédiatement (PostgreSQL)")
+	generateRegistry := flags.Bool("generate-registry", false, "Régénère le fichier registry_gen.go du dossier des migrations")
+	migrationsDir := flags.String("dir", "migrations", "Directory containing migrations")
+	allSchemas := flags.Bool("all-schemas", false, "Applique -migrate, -rollback, -to, -steps ou -rollback-all à chaque schéma locataire (PostgreSQL)")
+	schemas := flags.String("schemas", "", "Avec -all-schemas, liste des schémas séparés par des virgules")
+	schemaPattern := flags.String("schema-pattern", "", "Avec -all-schemas, motif LIKE des schémas (défaut: tous les schémas hors système, public et DB_SCHEMA)")
+	schemaQuery := flags.String("schema-query", "", "Avec -all-schemas, requête SQL retournant les schémas")
+	parallel := flags.Int("parallel", 4, "Avec -all-schemas, nombre de schémas migrés en même temps")
+	flags.Parse(os.Args[1:])
+
+	// Configuration par défaut
+	config := DefaultConfig()
+	config.DryRun = *dryRun
+	if *lockTimeout > 0 {
+		config.LockWaitTimeout = *lockTimeout
+	}
+	if !*dryRun {
+		config.SchemaDumpPath = *dumpSchema
+	}
+
+	// Si on veut créer une migration, on le fait avant de se connecter à la base de données
+	if *createMigration != "" {
+		generator := NewMigrationGenerator(*migrationsDir, config)
+		generate := generator.GenerateMigration
+		if *sqlMigration {
+			generate = generator.GenerateSQLMigration
+		}
+		if err := generate(*createMigration); err != nil {
+			log.Fatalf("Erreur lors de la création de la migration: %v", err)
+		}
+		return
+	}
+
+	if *generateRegistry {
+		generator := NewMigrationGenerator(*migrationsDir, config)
+		if err := generator.GenerateRegistry(); err != nil {
+			log.Fatalf("Erreur lors de la génération du registre des migrations: %v", err)
+		}
+		return
+	}
+
+	// Configuration de la base de données
+	dbConfig, err := LoadConfig()
+	if err != nil {
+		log.Fatalf("Erreur de configuration de la base de données: %v", err)
+	}
+
+	if *dumpSchema != "" && dbConfig.Driver != DriverPostgres {
+		log.Fatalf("-dump-schema n'est supporté qu'avec PostgreSQL")
+	}
+	if *dumpSchema != "" && *allSchemas {
+		log.Fatalf("-dump-schema ne s'utilise pas avec -all-schemas")
+	}
+
+	// Connexion à la base de données
+	conn, err := NewConnection(dbConfig)
+	if err != nil {
+		log.Fatalf("Erreur de connexion à la base de données: %v", err)
+	}
+	defer conn.Close()
+
+	if *autogen != "" {
+		models := registry.Models()
+		if len(models) == 0 {
+			log.Fatalf("Aucun modèle enregistré: appelez RegisterModel ou RegisterGlobalModel avant Main")
+		}
+
+		generator := NewMigrationGenerator(*migrationsDir, config).WithDB(conn.DB())
+		if err := generator.GenerateFromModels(*autogen, models...); err != nil {
+			if errors.Is(err, ErrNoSchemaChanges) {
+				fmt.Println("Le schéma de la base correspond aux modèles, aucune migration créée")
+				return
+			}
+			log.Fatalf("Erreur lors de la génération de la migration: %v", err)
+		}
+		return
+	}
+
+	// Création du migrator
+	migrator := NewMigrator(conn.DB(), config)
+
+	// Création du découvreur de migrations
+	discovery := NewMigrationDiscoveryWithRegistry(*migrationsDir, registry)
+
+	if *forceUnlock {
+		pid, err := migrator.ForceUnlock()
+		if err != nil {
+			log.Fatalf("Erreur lors de la libération du verrou de migration: %v", err)
+		}
+		if pid == 0 {
+			fmt.Println("Aucun verrou de migration détenu")
+			return
+		}
+		fmt.Printf("Verrou de migration libéré (session %d terminée)\n", pid)
+		return
+	}
+
+	if *allSchemas {
+		operation := tenantOperation(*migrate, *rollback, *target, *steps, *rollbackAll)
+		if operation == nil {
+			log.Fatalf("-all-schemas s'utilise avec -migrate, -rollback, -to, -steps ou -rollback-all")
+		}
+
+		migrations, err := discoverMigrations(discovery, registry)
+		if err != nil {
+			log.Fatalf("Erreur lors de la découverte des migrations: %v", err)
+		}
+
+		tenants := NewTenantMigrator(conn.DB(), config, &TenantConfig{
+			Schemas:     splitList(*schemas),
+			Query:       *schemaQuery,
+			Pattern:     *schemaPattern,
+			Parallelism: *parallel,
+		})
+		results, err := tenants.ForEach(context.Background(), func(m *Migrator) error {
+			return operation(m, migrations)
+		})
+		if results == nil && err != nil {
+			log.Fatalf("Erreur lors de la recherche des schémas: %v", err)
+		}
+		if !printTenantSummary(os.Stdout, results) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *status {
+		migrations, err := discoverMigrations(discovery, registry)
+		if err != nil {
+			log.Fatalf("Erreur lors de la découverte des migrations: %v", err)
+		}
+
+		statuses, err := migrator.Status(migrations)
+		if err != nil {
+			log.Fatalf("Erreur lors de la récupération de l'état des migrations: %v", err)
+		}
+		if err := printStatus(os.Stdout, statuses, *format); err != nil {
+			log.Fatalf("Erreur lors de l'affichage de l'état des migrations: %v", err)
+		}
+		return
+	}
+
+	if *repair {
+		migrations, err := discoverMigrations(discovery, registry)
+		if err != nil {
+			log.Fatalf("Erreur lors de la découverte des migrations: %v", err)
+		}
+
+		updated, err := migrator.RepairChecksums(migrations...)
+		if err != nil {
+			log.Fatalf("Erreur lors de la réparation des sommes de contrôle: %v", err)
+		}
+		fmt.Printf("%d somme(s) de contrôle réenregistrée(s)\n", updated)
+		return
+	}
+
+	if *target != "" || *steps > 0 || *rollbackAll {
+		migrations, err := discoverMigrations(discovery, registry)
+		if err != nil {
+			log.Fatalf("Erreur lors de la découverte des migrations: %v", err)
+		}
+
+		switch {
+		case *target != "":
+			err = migrator.MigrateTo(*target, migrations)
+		case *steps > 0:
+			err = migrator.RollbackSteps(*steps, migrations)
+		default:
+			err = migrator.Reset(migrations)
+		}
+		if err != nil {
+			log.Fatalf("Erreur lors de l'exécution des migrations: %v", err)
+		}
+		if !config.DryRun {
+			fmt.Println("Migrations exécutées avec succès")
+		}
+		return
+	}
+
+	if *migrate {
+		// Découvrir et exécuter les migrations
+		migrations, err := discoverMigrations(discovery, registry)
+		if err != nil {
+			log.Fatalf("Erreur lors de la découverte des migrations: %v", err)
+		}
+
+		if err := migrator.RunMigrations(migrations...); err != nil {
+			log.Fatalf("Erreur lors de l'exécution des migrations: %v", err)
+		}
+		if !config.DryRun {
+			fmt.Println("Migrations exécutées avec succès")
+		}
+		return
+	}
+
+	if *rollback {
+		// Récupérer les migrations appliquées
+		appliedMigrations, err := migrator.GetAppliedMigrations()
+		if err != nil {
+			log.Fatalf("Erreur lors de la récupération des migrations appliquées: %v", err)
+		}
+
+		if len(appliedMigrations) == 0 {
+			log.Println("Aucune migration à annuler")
+			return
+		}
+
+		// Récupérer la dernière migration appliquée
+		lastMigration := appliedMigrations[len(appliedMigrations)-1]
+
+		// Découvrir toutes les migrations
+		migrations, err := discoverMigrations(discovery, registry)
+		if err != nil {
+			log.Fatalf("Erreur lors de la découverte des migrations: %v", err)
+		}
+
+		// Trouver la migration correspondante
+		var targetMigration Migration
+		for _, m := range migrations {
+			if m.Name() == lastMigration.Name {
+				targetMigration = m
+				break
+			}
+		}
+
+		if targetMigration == nil {
+			log.Fatalf("Migration %s non trouvée", lastMigration.Name)
+		}
+
+		// Exécuter le rollback
+		if err := migrator.RollbackMigration(targetMigration); err != nil {
+			log.Fatalf("Erreur lors du rollback de la migration: %v", err)
+		}
+		if !config.DryRun {
+			log.Printf("Migration %s annulée avec succès", lastMigration.Name)
+		}
+		return
+	}
+
+	if *dumpSchema != "" {
+		var dump bytes.Buffer
+		if err := migrator.DumpSchema(&dump); err != nil {
+			log.Fatalf("Erreur lors du dump du schéma: %v", err)
+		}
+		if err := os.WriteFile(*dumpSchema, dump.Bytes(), DefaultFileMode); err != nil {
+			log.Fatalf("Erreur lors de l'écriture du dump du schéma: %v", err)
+		}
+		fmt.Printf("Schéma écrit dans %s\n", *dumpSchema)
+		return
+	}
+
+	// Si aucun flag n'est spécifié, afficher l'aide
+	flags.Usage()
+	os.Exit(1)
+}
+
+// discoverMigrations retourne les migrations du dossier -dir. Un binaire
+// déployé sans ce dossier exécute les migrations de son registre, triées par
+// nom. Un dossier qui ne contient aucune des migrations du registre est une
+// erreur plutôt que « rien à migrer ».
+func discoverMigrations(discovery *MigrationDiscovery, registry *MigrationRegistry) ([]Migration, error) {
+	registered := registry.GetAllMigrations()
+
+	migrations, err := discovery.DiscoverMigrations()
+	if errors.Is(err, fs.ErrNotExist) && len(registered) > 0 {
+		return registered, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(migrations) == 0 && len(registered) > 0 {
+		return nil, fmt.Errorf("aucune migration trouvée dans %s alors que le registre en contient %d", discovery.MigrationsDir, len(registered))
+	}
+	return migrations, nil
+}
+
+// tenantOperation retourne l'opération de migration demandée pour chaque
+// schéma, ou nil si aucune ne l'est. -rollback annule la dernière migration
+// appliquée de chaque schéma.
+func tenantOperation(migrate, rollback bool, target string, steps int, rollbackAll bool) func(m *Migrator, migrations []Migration) error {
+	switch {
+	case target != "":
+		return func(m *Migrator, migrations []Migration) error { return m.MigrateTo(target, migrations) }
+	case steps > 0:
+		return func(m *Migrator, migrations []Migration) error { return m.RollbackSteps(steps, migrations) }
+	case rollbackAll:
+		return func(m *Migrator, migrations []Migration) error { return m.Reset(migrations) }
+	case migrate:
+		return func(m *Migrator, migrations []Migration) error { return m.RunMigrations(migrations...) }
+	case rollback:
+		return func(m *Migrator, migrations []Migration) error { return m.RollbackSteps(1, migrations) }
+	default:
+		return nil
+	}
+}
+
+// printTenantSummary affiche le résultat de chaque schéma et indique si tous
+// ont réussi
+func printTenantSummary(w io.Writer, results []TenantResult) bool {
+	failed := 0
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SCHÉMA\tRÉSULTAT\tDURÉE")
+	for _, result := range results {
+		outcome := "ok"
+		if result.Err != nil {
+			failed++
+			outcome = "échec: " + result.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", result.Schema, outcome, result.Duration.Round(time.Millisecond))
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "%d schéma(s) sur %d en échec\n", failed, len(results))
+	return failed == 0
+}
+
+// printStatus affiche l'état des migrations sous forme de tableau ou de JSON
+func printStatus(w io.Writer, statuses []MigrationStatus, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(statuses)
+
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "MIGRATION\tÉTAT\tAPPLIQUÉE LE")
+		for _, status := range statuses {
+			appliedAt := "-"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", status.Name, status.State, appliedAt)
+		}
+		return tw.Flush()
+
+	default:
+		return fmt.Errorf("format inconnu: %s (table ou json)", format)
+	}
+}
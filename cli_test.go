@@ -0,0 +1,62 @@
+package gormlib
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestDiscoverMigrationsFallsBackToRegistry(t *testing.T) {
+	registry := NewMigrationRegistry()
+	for _, name := range []string{"20240102000000_add_email", "20240101000000_create_users"} {
+		if err := registry.Register(NewSQLMigration(name, "SELECT 1", "")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "dossier absent",
+			files: fstest.MapFS{},
+			want:  []string{"20240101000000_create_users", "20240102000000_add_email"},
+		},
+		{
+			name: "dossier sans les migrations du registre",
+			files: fstest.MapFS{
+				"migrations/README.md": &fstest.MapFile{Data: []byte("notes")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "dossier avec les migrations",
+			files: fstest.MapFS{
+				"migrations/20240101000000_create_users.go": &fstest.MapFile{Data: []byte("package migrations")},
+			},
+			want: []string{"20240101000000_create_users"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			discovery := NewMigrationDiscoveryFS(tt.files, "migrations")
+			discovery.registry = registry
+
+			migrations, err := discoverMigrations(discovery, registry)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("discoverMigrations() erreur = %v, attendu erreur = %v", err, tt.wantErr)
+			}
+			if len(migrations) != len(tt.want) {
+				t.Fatalf("discoverMigrations() = %d migration(s), attendu %v", len(migrations), tt.want)
+			}
+			for i, migration := range migrations {
+				if migration.Name() != tt.want[i] {
+					t.Errorf("migration %d = %s, attendu %s", i, migration.Name(), tt.want[i])
+				}
+			}
+		})
+	}
+}
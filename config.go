@@ -1,51 +1,183 @@
 package gormlib
 
 import (
-	"fmt"
+	"context"
+	"crypto/tls"
+	"io"
+	"log/slog"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // Config contient la configuration de la base de données
 type Config struct {
+	Driver          string // postgres (par défaut), mysql, sqlite ou sqlserver
 	Host            string
 	Port            int
 	User            string
 	Password        string
+	Credentials     CredentialProvider // Si défini, fournit User et Password à chaque connexion
 	Database        string
 	Schema          string
 	SSLMode         string
+	SSLRootCert     string      // Certificat de l'autorité de confiance (sslrootcert)
+	SSLCert         string      // Certificat client (sslcert)
+	SSLKey          string      // Clé privée du certificat client (sslkey)
+	SSLPassword     string      // Mot de passe de la clé privée (sslpassword)
+	TLSConfig       *tls.Config // Si défini, remplace la configuration TLS issue de SSLMode et des fichiers
 	MaxIdleConns    int
 	MaxOpenConns    int
 	ConnMaxLifetime time.Duration
+	ConnectRetries  int           // Nouvelles tentatives si la base n'est pas encore joignable
+	ConnectTimeout  time.Duration // Délai maximum de chaque tentative de connexion
+	LogLevel        string        // Journalisation SQL: silent, error, warn (par défaut) ou info
+	SlowThreshold   time.Duration // Durée au-delà de laquelle une requête est journalisée comme lente
+	RedactParams    bool          // Journaliser les requêtes sans la valeur de leurs paramètres
+	Logger          *slog.Logger  // Si défini, les journaux SQL sont écrits dans ce logger
+
+	// CreateSchemaIfMissing crée Schema à l'ouverture de la connexion s'il
+	// n'existe pas (PostgreSQL et SQL Server), avec SchemaOwner pour
+	// propriétaire s'il est renseigné
+	CreateSchemaIfMissing bool
+	SchemaOwner           string
+
+	// Réplicas en lecture seule (hôte ou hôte:port), avec la base et les
+	// identifiants du primaire
+	ReplicaHosts        []string
+	ReplicaPolicy       string        // Répartition des lectures: random (par défaut), round-robin ou least-conn
+	ReplicaMaxIdleConns int           // Connexions inactives par réplica (0 = MaxIdleConns)
+	ReplicaMaxOpenConns int           // Connexions ouvertes par réplica (0 = MaxOpenConns)
+	ReplicaMaxLag       time.Duration // Retard au-delà duquel un réplica est écarté (0 = pas de limite)
 }
 
-// NewConfig crée une nouvelle configuration à partir des variables d'environnement
+// NewConfig crée une nouvelle configuration à partir des variables
+// d'environnement. Les valeurs invalides sont ignorées : utilisez LoadConfig
+// pour les détecter.
 func NewConfig() *Config {
-	port, _ := strconv.Atoi(getEnv("DB_PORT", "5432"))
-	maxIdleConns, _ := strconv.Atoi(getEnv("DB_MAX_IDLE_CONNS", "10"))
-	maxOpenConns, _ := strconv.Atoi(getEnv("DB_MAX_OPEN_CONNS", "100"))
-	connMaxLifetime, _ := time.ParseDuration(getEnv("DB_CONN_MAX_LIFETIME", "1h"))
-
-	return &Config{
-		Host:            getEnv("DB_HOST", "localhost"),
-		Port:            port,
-		User:            getEnv("DB_USER", "postgres"),
-		Password:        getEnv("DB_PASSWORD", "postgres"),
-		Database:        getEnv("DB_NAME", "oauth"),
-		Schema:          getEnv("DB_SCHEMA", "public"),
-		SSLMode:         getEnv("DB_SSLMODE", "disable"),
-		MaxIdleConns:    maxIdleConns,
-		MaxOpenConns:    maxOpenConns,
-		ConnMaxLifetime: connMaxLifetime,
+	config, _ := loadConfig()
+	return config
+}
+
+// LoadConfig crée une nouvelle configuration à partir des variables
+// d'environnement et la valide. L'erreur retournée regroupe toutes les
+// variables invalides.
+func LoadConfig() (*Config, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return nil, err
 	}
+	return config, nil
 }
 
-// DSN retourne la chaîne de connexion PostgreSQL
+// loadConfig lit la configuration depuis l'environnement. La configuration est
+// toujours retournée, avec une valeur nulle pour chaque variable invalide.
+func loadConfig() (*Config, error) {
+	env := &envParser{}
+
+	// DB_URL (ou DATABASE_URL) fournit les valeurs par défaut des variables DB_*
+	base := &Config{}
+	if rawURL := getEnv("DB_URL", os.Getenv("DATABASE_URL")); rawURL != "" {
+		parsed, err := ParseURL(rawURL)
+		if err != nil {
+			env.errs = append(env.errs, &fieldError{"DB_URL", err})
+		} else {
+			base = parsed
+		}
+	}
+
+	driver := getEnv("DB_DRIVER", orDefault(base.Driver, DriverPostgres))
+	port := strconv.Itoa(defaultPort(driver))
+	if base.Port != 0 {
+		port = strconv.Itoa(base.Port)
+	}
+
+	config := &Config{
+		Driver:          driver,
+		Host:            getEnv("DB_HOST", orDefault(base.Host, "localhost")),
+		Port:            env.int("DB_PORT", port),
+		User:            getEnv("DB_USER", orDefault(base.User, "postgres")),
+		Password:        getEnv("DB_PASSWORD", orDefault(base.Password, "postgres")),
+		Database:        getEnv("DB_NAME", orDefault(base.Database, "oauth")),
+		Schema:          getEnv("DB_SCHEMA", orDefault(base.Schema, defaultSchema(driver))),
+		SSLMode:         getEnv("DB_SSLMODE", orDefault(base.SSLMode, "disable")),
+		SSLRootCert:     getEnv("DB_SSLROOTCERT", base.SSLRootCert),
+		SSLCert:         getEnv("DB_SSLCERT", base.SSLCert),
+		SSLKey:          getEnv("DB_SSLKEY", base.SSLKey),
+		SSLPassword:     getEnv("DB_SSLPASSWORD", base.SSLPassword),
+		MaxIdleConns:    env.int("DB_MAX_IDLE_CONNS", "10"),
+		MaxOpenConns:    env.int("DB_MAX_OPEN_CONNS", "100"),
+		ConnMaxLifetime: env.duration("DB_CONN_MAX_LIFETIME", "1h"),
+		ConnectRetries:  env.int("DB_CONNECT_RETRIES", "5"),
+		ConnectTimeout:  env.duration("DB_CONNECT_TIMEOUT", "10s"),
+		LogLevel:        getEnv("DB_LOG_LEVEL", LogLevelWarn),
+		SlowThreshold:   env.duration("DB_SLOW_THRESHOLD", "200ms"),
+		RedactParams:    env.bool("DB_LOG_REDACT_PARAMS", "true"),
+
+		CreateSchemaIfMissing: env.bool("DB_CREATE_SCHEMA", "false"),
+		SchemaOwner:           os.Getenv("DB_SCHEMA_OWNER"),
+
+		ReplicaHosts:        splitList(os.Getenv("DB_REPLICA_HOSTS")),
+		ReplicaPolicy:       getEnv("DB_REPLICA_POLICY", ReplicaPolicyRandom),
+		ReplicaMaxIdleConns: env.int("DB_REPLICA_MAX_IDLE_CONNS", "0"),
+		ReplicaMaxOpenConns: env.int("DB_REPLICA_MAX_OPEN_CONNS", "0"),
+		ReplicaMaxLag:       env.duration("DB_REPLICA_MAX_LAG", "0s"),
+	}
+
+	// Secrets montés en fichiers (DB_USER_FILE, DB_PASSWORD_FILE), relus à
+	// chaque nouvelle connexion pour suivre leur renouvellement
+	if provider := NewSecretFileCredentialProvider(); provider != nil {
+		config.Credentials = provider
+		if resolved, err := config.withCredentials(context.Background()); err != nil {
+			env.errs = append(env.errs, &fieldError{"DB_PASSWORD_FILE", err})
+		} else {
+			config.User, config.Password = resolved.User, resolved.Password
+		}
+	}
+
+	// Une variable illisible n'est pas signalée une seconde fois par la
+	// validation de sa valeur nulle
+	errs := env.errs
+	for _, err := range config.validationErrors() {
+		if !env.failed(err.key) {
+			errs = append(errs, err)
+		}
+	}
+	return config, configError(errs)
+}
+
+// DSN retourne la chaîne de connexion au format du pilote configuré
 func (c *Config) DSN() string {
-	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s search_path=%s sslmode=%s",
-		c.Host, c.Port, c.User, c.Password, c.Database, c.Schema, c.SSLMode)
+	switch c.driver() {
+	case DriverMySQL:
+		return c.mysqlDSN()
+	case DriverSQLite:
+		return c.sqliteDSN()
+	case DriverSQLServer:
+		return c.sqlserverDSN()
+	default:
+		return c.postgresDSN()
+	}
+}
+
+// orDefault retourne value, ou defaultValue si value est vide
+func orDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// splitList découpe une liste séparée par des virgules, sans les éléments vides
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 // getEnv récupère une variable d'environnement avec une valeur par défaut
@@ -56,32 +188,105 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// TransactionMode définit comment les migrations sont regroupées en transactions
+type TransactionMode string
+
+const (
+	// TransactionPerBatch exécute chaque lot de BatchSize migrations dans une
+	// transaction (mode par défaut)
+	TransactionPerBatch TransactionMode = "per-batch"
+
+	// TransactionPerMigration exécute chaque migration dans sa propre transaction
+	TransactionPerMigration TransactionMode = "per-migration"
+
+	// TransactionAll exécute toutes les migrations dans une seule transaction
+	TransactionAll TransactionMode = "all-in-one"
+
+	// TransactionNone exécute les migrations hors de toute transaction
+	TransactionNone TransactionMode = "none"
+)
+
 // MigrationConfig contient la configuration pour les migrations
 type MigrationConfig struct {
 	// BatchSize est le nombre de migrations à exécuter en une seule transaction
 	BatchSize int
 
-	// Timeout est le délai maximum pour l'exécution d'une migration
+	// TransactionMode définit le regroupement des migrations en transactions.
+	// Les migrations qui implémentent NonTransactionalMigration s'exécutent
+	// toujours hors transaction.
+	TransactionMode TransactionMode
+
+	// Timeout est le délai maximum de RunMigrations et RollbackMigration
 	Timeout time.Duration
 
-	// RetryAttempts est le nombre de tentatives en cas d'échec
+	// MigrationTimeout est le délai maximum de chaque migration (0 = pas de
+	// limite). Une migration qui implémente MigrationWithTimeout a son propre délai.
+	MigrationTimeout time.Duration
+
+	// StatementTimeout est le statement_timeout PostgreSQL appliqué pendant
+	// chaque migration (0 = valeur du serveur)
+	StatementTimeout time.Duration
+
+	// LockTimeout est le lock_timeout PostgreSQL appliqué pendant chaque
+	// migration (0 = valeur du serveur)
+	LockTimeout time.Duration
+
+	// RetryAttempts est le nombre de tentatives en cas d'échec transitoire
+	// (échec de sérialisation, deadlock, expiration de verrou, perte de connexion)
 	RetryAttempts int
 
+	// RetryBackoff est le délai avant la deuxième tentative, doublé à chaque
+	// nouvelle tentative
+	RetryBackoff time.Duration
+
+	// RetryMaxBackoff borne le délai entre deux tentatives (0 = pas de borne)
+	RetryMaxBackoff time.Duration
+
+	// RetryJitter est la part aléatoire du délai entre deux tentatives
+	// (0.2 = ±20%)
+	RetryJitter float64
+
 	// TableName est le nom de la table qui stocke les migrations
 	TableName string
 
+	// LegacyTableName est l'ancienne table d'historique dont le contenu est
+	// importé lors de la création de TableName (vide = pas d'import)
+	LegacyTableName string
+
 	// AutoCreateDir indique si le dossier des migrations doit être créé automatiquement
 	AutoCreateDir bool
+
+	// DryRun affiche le SQL des migrations au lieu de l'exécuter. Ni les
+	// données ni l'historique des migrations ne sont modifiés.
+	DryRun bool
+
+	// DryRunOutput est la sortie du SQL en mode DryRun (os.Stdout si nil)
+	DryRunOutput io.Writer
+
+	// LockWaitTimeout est le délai maximum d'attente du verrou de migration
+	// détenu par une autre instance (0 = attente illimitée)
+	LockWaitTimeout time.Duration
+
+	// SchemaDumpPath est le fichier réécrit avec le dump du schéma (voir
+	// Migrator.DumpSchema) après chaque migration ou rollback réussi (vide =
+	// pas de dump). PostgreSQL uniquement.
+	SchemaDumpPath string
 }
 
 // DefaultConfig retourne la configuration par défaut
 func DefaultConfig() *MigrationConfig {
 	return &MigrationConfig{
-		BatchSize:     10,
-		Timeout:       5 * time.Minute,
-		RetryAttempts: 3,
-		TableName:     "migrations",
-		AutoCreateDir: true,
+		BatchSize:       10,
+		TransactionMode: TransactionPerBatch,
+		Timeout:         5 * time.Minute,
+		RetryAttempts:   3,
+		RetryBackoff:    time.Second,
+		RetryMaxBackoff: 30 * time.Second,
+		RetryJitter:     0.2,
+		TableName:       "migrations",
+		LegacyTableName: "migration_records",
+		AutoCreateDir:   true,
+		LockWaitTimeout: time.Minute,
 	}
 }
 
@@ -90,6 +295,12 @@ const (
 	// MigrationFileSuffix est le suffixe des fichiers de migration
 	MigrationFileSuffix = ".go"
 
+	// SQLUpSuffix est le suffixe des fichiers SQL appliquant une migration
+	SQLUpSuffix = ".up.sql"
+
+	// SQLDownSuffix est le suffixe des fichiers SQL annulant une migration
+	SQLDownSuffix = ".down.sql"
+
 	// MigrationStructPrefix est le préfixe des structures de migration
 	MigrationStructPrefix = "Migration"
 
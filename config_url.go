@@ -0,0 +1,173 @@
+package gormlib
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// redactedPassword remplace le mot de passe dans les URL et messages affichés
+const redactedPassword = "xxxxx"
+
+// URL retourne la configuration de connexion sous forme d'URL :
+// postgres://, mysql://, sqlserver:// ou sqlite://
+func (c *Config) URL() string {
+	return c.url().String()
+}
+
+// Redacted retourne l'URL de connexion avec les mots de passe masqués
+func (c *Config) Redacted() string {
+	u := c.url()
+	if query := u.Query(); query.Get("sslpassword") != "" {
+		query.Set("sslpassword", redactedPassword)
+		u.RawQuery = query.Encode()
+	}
+	return u.Redacted()
+}
+
+// String retourne l'URL de connexion avec le mot de passe masqué, afin que la
+// configuration puisse être journalisée sans exposer le mot de passe
+func (c *Config) String() string {
+	return c.Redacted()
+}
+
+// url construit l'URL de connexion correspondant au pilote configuré
+func (c *Config) url() *url.URL {
+	driver := c.driver()
+	if driver == DriverSQLite {
+		return &url.URL{Scheme: DriverSQLite, Opaque: c.Database}
+	}
+
+	u := &url.URL{
+		Scheme: driver,
+		User:   url.UserPassword(c.User, c.Password),
+		Host:   net.JoinHostPort(c.Host, strconv.Itoa(c.Port)),
+	}
+
+	query := url.Values{}
+	switch driver {
+	case DriverSQLServer:
+		query.Set("database", c.Database)
+	default:
+		u.Path = "/" + c.Database
+	}
+	if driver == DriverPostgres {
+		if c.SSLMode != "" {
+			query.Set("sslmode", c.SSLMode)
+		}
+		if c.Schema != "" {
+			query.Set("search_path", c.searchPath())
+		}
+		for key, value := range map[string]string{
+			"sslrootcert": c.SSLRootCert,
+			"sslcert":     c.SSLCert,
+			"sslkey":      c.SSLKey,
+			"sslpassword": c.SSLPassword,
+		} {
+			if value != "" {
+				query.Set(key, value)
+			}
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	return u
+}
+
+// ParseURL lit une URL de connexion (postgres://, postgresql://, mysql://,
+// sqlserver:// ou sqlite://) dans une configuration. Seuls les champs présents
+// dans l'URL sont renseignés.
+func ParseURL(rawURL string) (*Config, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// L'erreur de url.Parse contient l'URL complète, mot de passe compris
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("URL de connexion invalide: %v", err)
+	}
+
+	config := &Config{}
+	switch u.Scheme {
+	case "postgres", "postgresql":
+		config.Driver = DriverPostgres
+	case DriverMySQL, DriverSQLServer:
+		config.Driver = u.Scheme
+	case DriverSQLite:
+		config.Driver = DriverSQLite
+		config.Database = u.Opaque
+		if config.Database == "" {
+			config.Database = u.Host + u.Path
+		}
+		return config, nil
+	default:
+		return nil, fmt.Errorf("URL de connexion invalide: schéma %q non supporté", u.Scheme)
+	}
+
+	config.Host = u.Hostname()
+	if port := u.Port(); port != "" {
+		config.Port, err = strconv.Atoi(port)
+		if err != nil {
+			return nil, fmt.Errorf("URL de connexion invalide: port %q", port)
+		}
+	}
+	if u.User != nil {
+		config.User = u.User.Username()
+		config.Password, _ = u.User.Password()
+	}
+
+	query := u.Query()
+	config.Database = strings.TrimPrefix(u.Path, "/")
+	if database := query.Get("database"); database != "" {
+		config.Database = database
+	}
+	config.SSLMode = query.Get("sslmode")
+	config.SSLRootCert = query.Get("sslrootcert")
+	config.SSLCert = query.Get("sslcert")
+	config.SSLKey = query.Get("sslkey")
+	config.SSLPassword = query.Get("sslpassword")
+	if searchPath := query.Get("search_path"); searchPath != "" {
+		config.Schema = unquoteIdentifier(searchPath)
+	}
+
+	return config, nil
+}
+
+// redactError retourne err avec toute occurrence des mots de passe masquée
+// dans son message. L'erreur d'origine reste accessible via errors.Is et
+// errors.As.
+func redactError(err error, passwords ...string) error {
+	if err == nil {
+		return err
+	}
+
+	msg := err.Error()
+	redacted := msg
+	for _, password := range passwords {
+		if password == "" {
+			continue
+		}
+		// Les formes échappées d'abord: elles contiennent souvent la forme brute
+		for _, form := range []string{quoteDSNValue(password), url.QueryEscape(password), url.PathEscape(password), password} {
+			redacted = strings.ReplaceAll(redacted, form, redactedPassword)
+		}
+	}
+	if redacted == msg {
+		return err
+	}
+	return &redactedError{err: err, msg: redacted}
+}
+
+// redactedError est une erreur dont le message a été expurgé du mot de passe
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string { return e.msg }
+
+func (e *redactedError) Unwrap() error { return e.err }
@@ -0,0 +1,162 @@
+package gormlib
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// testPasswords sont des mots de passe qui doivent être échappés dans les
+// chaînes de connexion et les URL
+var testPasswords = []struct {
+	name     string
+	password string
+}{
+	{"simple", "s3cr3t"},
+	{"espace", "mot de passe"},
+	{"apostrophe", "it's"},
+	{"antislash", `a\b`},
+	{"apostrophe échappée", `a\'b`},
+	{"caractères d'URL", "p@ss:w/rd?#%"},
+	{"vide", ""},
+}
+
+func TestQuoteDSNValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"s3cr3t", "s3cr3t"},
+		{"", "''"},
+		{"mot de passe", "'mot de passe'"},
+		{"it's", `'it\'s'`},
+		{`a\b`, `'a\\b'`},
+		{"tab\tulation", "'tab\tulation'"},
+	}
+
+	for _, tt := range tests {
+		if got := quoteDSNValue(tt.value); got != tt.want {
+			t.Errorf("quoteDSNValue(%q) = %s, attendu %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestURLRoundTrip(t *testing.T) {
+	for _, tt := range testPasswords {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, driver := range []string{DriverPostgres, DriverMySQL} {
+				original := &Config{Driver: driver, Host: "db.internal", Port: 5432, User: "app", Password: tt.password, Database: "app", SSLMode: "disable"}
+
+				config, err := ParseURL(original.URL())
+				if err != nil {
+					t.Fatalf("%s: ParseURL() erreur = %v", driver, err)
+				}
+				if config.Password != tt.password || config.User != "app" || config.Host != "db.internal" || config.Database != "app" {
+					t.Fatalf("%s: ParseURL() = %+v, attendu %+v", driver, config, original)
+				}
+
+				// Le mot de passe lu par le pilote est celui de l'URL
+				var password string
+				switch driver {
+				case DriverPostgres:
+					pgConfig, err := pgconn.ParseConfig(config.DSN())
+					if err != nil {
+						t.Fatalf("DSN PostgreSQL invalide: %v", err)
+					}
+					password = pgConfig.Password
+				case DriverMySQL:
+					mysqlConfig, err := mysqldriver.ParseDSN(config.DSN())
+					if err != nil {
+						t.Fatalf("DSN MySQL invalide: %v", err)
+					}
+					password = mysqlConfig.Passwd
+				}
+				if password != tt.password {
+					t.Errorf("%s: mot de passe du DSN = %q, attendu %q", driver, password, tt.password)
+				}
+			}
+		})
+	}
+}
+
+func TestParseURLSearchPath(t *testing.T) {
+	config, err := ParseURL("postgresql://app@localhost:5432/app?search_path=%22Tenant%22&sslmode=require")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Driver != DriverPostgres || config.Schema != "Tenant" || config.SSLMode != "require" {
+		t.Errorf("ParseURL() = %+v", config)
+	}
+}
+
+func TestParseURLErrorHidesPassword(t *testing.T) {
+	_, err := ParseURL("postgres://app:s3cr3t@db:5432/app\x7f")
+	if err == nil {
+		t.Fatal("ParseURL() n'a pas retourné d'erreur")
+	}
+	if strings.Contains(err.Error(), "s3cr3t") {
+		t.Errorf("ParseURL() erreur = %q, contient le mot de passe", err)
+	}
+}
+
+// passwordForms retourne les formes sous lesquelles un mot de passe peut
+// apparaître dans un message
+func passwordForms(password string) []string {
+	return []string{password, quoteDSNValue(password), url.QueryEscape(password), url.PathEscape(password)}
+}
+
+func TestRedactedHidesPasswords(t *testing.T) {
+	for _, tt := range testPasswords {
+		if tt.password == "" {
+			continue
+		}
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Host: "db", Port: 5432, User: "app", Password: tt.password, Database: "app", SSLPassword: tt.password + "-key"}
+
+			for name, value := range map[string]string{"Redacted": config.Redacted(), "String": config.String(), "Sprint": fmt.Sprint(config)} {
+				for _, form := range passwordForms(tt.password) {
+					if strings.Contains(value, form) {
+						t.Errorf("%s() = %s, contient le mot de passe", name, value)
+					}
+				}
+				if !strings.Contains(value, redactedPassword) {
+					t.Errorf("%s() = %s, attendu le mot de passe masqué", name, value)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactErrorHidesPasswords(t *testing.T) {
+	cause := errors.New("connexion refusée")
+	for _, tt := range testPasswords {
+		if tt.password == "" {
+			continue
+		}
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Host: "db", Port: 5432, User: "app", Password: tt.password, Database: "app"}
+			for _, form := range passwordForms(tt.password) {
+				err := fmt.Errorf("connexion à %q (%s) impossible: %w", config.DSN(), form, cause)
+
+				redacted := redactError(err, tt.password)
+				for _, leaked := range passwordForms(tt.password) {
+					if strings.Contains(redacted.Error(), leaked) {
+						t.Errorf("redactError() = %s, contient le mot de passe", redacted)
+					}
+				}
+				if !errors.Is(redacted, cause) {
+					t.Errorf("redactError() ne permet plus d'atteindre l'erreur d'origine")
+				}
+			}
+		})
+	}
+
+	if err := redactError(cause, ""); err != cause {
+		t.Errorf("redactError() avec un mot de passe vide = %v, attendu l'erreur d'origine", err)
+	}
+}
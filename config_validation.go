@@ -0,0 +1,187 @@
+package gormlib
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// sslModes sont les valeurs de sslmode reconnues par libpq
+var sslModes = map[string]bool{
+	"disable":     true,
+	"allow":       true,
+	"prefer":      true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+// identifierPattern correspond à un identifiant SQL non quoté
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+
+// maxIdentifierLength est la longueur maximum d'un identifiant PostgreSQL
+const maxIdentifierLength = 63
+
+// fieldError est une erreur portant sur une variable de configuration
+type fieldError struct {
+	key string
+	err error
+}
+
+func (e *fieldError) Error() string {
+	return e.key + ": " + e.err.Error()
+}
+
+func (e *fieldError) Unwrap() error {
+	return e.err
+}
+
+// envParser lit des variables d'environnement typées en accumulant les
+// erreurs de conversion
+type envParser struct {
+	errs []*fieldError
+}
+
+// failed indique si la variable key n'a pas pu être convertie
+func (p *envParser) failed(key string) bool {
+	for _, e := range p.errs {
+		if e.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *envParser) int(key, defaultValue string) int {
+	value := getEnv(key, defaultValue)
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		p.errs = append(p.errs, &fieldError{key, fmt.Errorf("entier invalide %q", value)})
+	}
+	return n
+}
+
+func (p *envParser) duration(key, defaultValue string) time.Duration {
+	value := getEnv(key, defaultValue)
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		p.errs = append(p.errs, &fieldError{key, fmt.Errorf("durée invalide %q (exemples: 30s, 5m, 1h)", value)})
+	}
+	return d
+}
+
+func (p *envParser) bool(key, defaultValue string) bool {
+	value := getEnv(key, defaultValue)
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		p.errs = append(p.errs, &fieldError{key, fmt.Errorf("booléen invalide %q", value)})
+	}
+	return b
+}
+
+// configError regroupe les erreurs de configuration en une seule erreur
+func configError(fieldErrs []*fieldError) error {
+	if len(fieldErrs) == 0 {
+		return nil
+	}
+
+	errs := make([]error, len(fieldErrs))
+	for i, err := range fieldErrs {
+		errs[i] = err
+	}
+	return fmt.Errorf("configuration invalide:\n%w", errors.Join(errs...))
+}
+
+// Validate vérifie la cohérence de la configuration et retourne une erreur
+// regroupant toutes les valeurs invalides
+func (c *Config) Validate() error {
+	return configError(c.validationErrors())
+}
+
+// validationErrors retourne les erreurs de validation de la configuration
+func (c *Config) validationErrors() []*fieldError {
+	var errs []*fieldError
+	driver := c.driver()
+
+	switch driver {
+	case DriverPostgres, DriverMySQL, DriverSQLite, DriverSQLServer:
+	default:
+		errs = append(errs, &fieldError{"DB_DRIVER", fmt.Errorf("pilote inconnu %q (postgres, mysql, sqlite ou sqlserver)", c.Driver)})
+	}
+
+	// SQLite n'utilise ni hôte ni port
+	if driver != DriverSQLite && (c.Port < 1 || c.Port > 65535) {
+		errs = append(errs, &fieldError{"DB_PORT", fmt.Errorf("port %d hors de l'intervalle 1-65535", c.Port)})
+	}
+
+	if c.MaxOpenConns < 0 {
+		errs = append(errs, &fieldError{"DB_MAX_OPEN_CONNS", fmt.Errorf("%d ne peut pas être négatif", c.MaxOpenConns)})
+	}
+	if c.MaxIdleConns < 0 {
+		errs = append(errs, &fieldError{"DB_MAX_IDLE_CONNS", fmt.Errorf("%d ne peut pas être négatif", c.MaxIdleConns)})
+	}
+	// MaxOpenConns à 0 signifie sans limite
+	if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		errs = append(errs, &fieldError{"DB_MAX_IDLE_CONNS", fmt.Errorf("%d est supérieur à DB_MAX_OPEN_CONNS (%d)", c.MaxIdleConns, c.MaxOpenConns)})
+	}
+
+	if c.ConnMaxLifetime < 0 {
+		errs = append(errs, &fieldError{"DB_CONN_MAX_LIFETIME", fmt.Errorf("%s ne peut pas être négatif", c.ConnMaxLifetime)})
+	}
+	if c.ConnectRetries < 0 {
+		errs = append(errs, &fieldError{"DB_CONNECT_RETRIES", fmt.Errorf("%d ne peut pas être négatif", c.ConnectRetries)})
+	}
+	if c.ConnectTimeout < 0 {
+		errs = append(errs, &fieldError{"DB_CONNECT_TIMEOUT", fmt.Errorf("%s ne peut pas être négatif", c.ConnectTimeout)})
+	}
+	if c.SlowThreshold < 0 {
+		errs = append(errs, &fieldError{"DB_SLOW_THRESHOLD", fmt.Errorf("%s ne peut pas être négatif", c.SlowThreshold)})
+	}
+
+	if _, err := ParseLogLevel(c.LogLevel); err != nil {
+		errs = append(errs, &fieldError{"DB_LOG_LEVEL", fmt.Errorf("%w (silent, error, warn ou info)", err)})
+	}
+
+	if driver == DriverPostgres && !sslModes[c.SSLMode] {
+		errs = append(errs, &fieldError{"DB_SSLMODE", fmt.Errorf("mode %q inconnu (disable, allow, prefer, require, verify-ca ou verify-full)", c.SSLMode)})
+	}
+
+	if (c.SSLCert == "") != (c.SSLKey == "") {
+		errs = append(errs, &fieldError{"DB_SSLCERT", errors.New("DB_SSLCERT et DB_SSLKEY doivent être définis ensemble")})
+	}
+
+	if c.Schema != "" && (!identifierPattern.MatchString(c.Schema) || len(c.Schema) > maxIdentifierLength) {
+		errs = append(errs, &fieldError{"DB_SCHEMA", fmt.Errorf("%q n'est pas un identifiant valide", c.Schema)})
+	}
+	if c.SchemaOwner != "" && (!identifierPattern.MatchString(c.SchemaOwner) || len(c.SchemaOwner) > maxIdentifierLength) {
+		errs = append(errs, &fieldError{"DB_SCHEMA_OWNER", fmt.Errorf("%q n'est pas un identifiant valide", c.SchemaOwner)})
+	}
+	if c.CreateSchemaIfMissing && c.Schema == "" && (driver == DriverPostgres || driver == DriverSQLServer) {
+		errs = append(errs, &fieldError{"DB_CREATE_SCHEMA", errors.New("DB_SCHEMA doit être défini")})
+	}
+
+	if len(c.ReplicaHosts) > 0 && driver == DriverSQLite {
+		errs = append(errs, &fieldError{"DB_REPLICA_HOSTS", errors.New("les réplicas ne sont pas supportés avec SQLite")})
+	}
+	for _, host := range c.ReplicaHosts {
+		if _, _, err := splitReplicaHost(host, c.Port); err != nil {
+			errs = append(errs, &fieldError{"DB_REPLICA_HOSTS", err})
+		}
+	}
+	if !replicaPolicies[c.ReplicaPolicy] {
+		errs = append(errs, &fieldError{"DB_REPLICA_POLICY", fmt.Errorf("politique %q inconnue (random, round-robin ou least-conn)", c.ReplicaPolicy)})
+	}
+	if c.ReplicaMaxOpenConns < 0 {
+		errs = append(errs, &fieldError{"DB_REPLICA_MAX_OPEN_CONNS", fmt.Errorf("%d ne peut pas être négatif", c.ReplicaMaxOpenConns)})
+	}
+	if c.ReplicaMaxIdleConns < 0 {
+		errs = append(errs, &fieldError{"DB_REPLICA_MAX_IDLE_CONNS", fmt.Errorf("%d ne peut pas être négatif", c.ReplicaMaxIdleConns)})
+	}
+	if c.ReplicaMaxLag < 0 {
+		errs = append(errs, &fieldError{"DB_REPLICA_MAX_LAG", fmt.Errorf("%s ne peut pas être négatif", c.ReplicaMaxLag)})
+	}
+
+	return errs
+}
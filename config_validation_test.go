@@ -0,0 +1,59 @@
+package gormlib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigFieldErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want []string // Variables signalées, chacune une seule fois
+	}{
+		{"configuration valide", map[string]string{"DB_PORT": "5433"}, nil},
+		{"port illisible", map[string]string{"DB_PORT": "abc"}, []string{"DB_PORT"}},
+		{"port hors intervalle", map[string]string{"DB_PORT": "70000"}, []string{"DB_PORT"}},
+		{"durée sans unité", map[string]string{"DB_CONNECT_TIMEOUT": "10"}, []string{"DB_CONNECT_TIMEOUT"}},
+		{"booléen invalide", map[string]string{"DB_CREATE_SCHEMA": "peut-être"}, []string{"DB_CREATE_SCHEMA"}},
+		{"sslmode inconnu", map[string]string{"DB_SSLMODE": "always"}, []string{"DB_SSLMODE"}},
+		{"pool incohérent", map[string]string{"DB_MAX_OPEN_CONNS": "10", "DB_MAX_IDLE_CONNS": "20"}, []string{"DB_MAX_IDLE_CONNS"}},
+		{"schéma invalide", map[string]string{"DB_SCHEMA": "app; DROP"}, []string{"DB_SCHEMA"}},
+		{"URL invalide", map[string]string{"DB_URL": "oracle://db/app"}, []string{"DB_URL"}},
+		{"plusieurs erreurs", map[string]string{"DB_PORT": "abc", "DB_LOG_LEVEL": "loud", "DB_REPLICA_POLICY": "nearest"}, []string{"DB_PORT", "DB_LOG_LEVEL", "DB_REPLICA_POLICY"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			config, err := LoadConfig()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("LoadConfig() erreur = %v", err)
+				}
+				if config == nil {
+					t.Fatal("LoadConfig() = nil")
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("LoadConfig() n'a pas retourné d'erreur, attendu %v", tt.want)
+			}
+			if config != nil {
+				t.Errorf("LoadConfig() = %+v, attendu nil en cas d'erreur", config)
+			}
+			for _, key := range tt.want {
+				if n := strings.Count(err.Error(), key+": "); n != 1 {
+					t.Errorf("LoadConfig() erreur = %q, %s signalée %d fois", err, key, n)
+				}
+			}
+			if got := strings.Count(err.Error(), "\n"); got != len(tt.want) {
+				t.Errorf("LoadConfig() erreur = %q, attendu %d variable(s)", err, len(tt.want))
+			}
+		})
+	}
+}
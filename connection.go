@@ -1,33 +1,54 @@
 package gormlib
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
-	"gorm.io/driver/postgres"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 	"gorm.io/gorm/schema"
+	"gorm.io/plugin/dbresolver"
 )
 
 // Connection représente une connexion à la base de données
 type Connection struct {
-	db *gorm.DB
+	db       *gorm.DB
+	primary  *gorm.DB
+	replicas *replicaSet
 }
 
-// NewConnection crée une nouvelle connexion à la base de données
+// Délais entre deux tentatives de connexion : doublé à chaque tentative,
+// jusqu'à connectMaxBackoff
+const (
+	connectBackoff    = time.Second
+	connectMaxBackoff = 30 * time.Second
+)
+
+// NewConnection crée une nouvelle connexion à la base de données. Tant que la
+// base n'est pas joignable, la connexion est retentée jusqu'à
+// Config.ConnectRetries fois avec un délai croissant.
 func NewConnection(config *Config) (*Connection, error) {
 	// Configuration du logger GORM
 	gormConfig := &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-		NamingStrategy: schema.NamingStrategy{
+		Logger: config.gormLogger(),
+		// La connexion est vérifiée par open, avec ConnectTimeout
+		DisableAutomaticPing: true,
+	}
+
+	// MySQL et SQLite n'ont pas de schéma distinct de la base de données
+	if config.Schema != "" && (config.driver() == DriverPostgres || config.driver() == DriverSQLServer) {
+		gormConfig.NamingStrategy = schema.NamingStrategy{
 			TablePrefix: fmt.Sprintf("%s.", config.Schema),
-		},
+		}
 	}
 
 	// Connexion à la base de données
-	db, err := gorm.Open(postgres.Open(config.DSN()), gormConfig)
+	db, err := openWithRetry(config, gormConfig)
 	if err != nil {
-		return nil, fmt.Errorf("erreur lors de la connexion à la base de données: %v", err)
+		return nil, fmt.Errorf("erreur lors de la connexion à la base de données: %w", redactError(err, config.Password, config.SSLPassword))
 	}
 
 	// Configuration du pool de connexions
@@ -40,24 +61,164 @@ func NewConnection(config *Config) (*Connection, error) {
 	sqlDB.SetMaxOpenConns(config.MaxOpenConns)
 	sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime)
 
-	// Définir le schéma par défaut
-	if err := db.Exec(fmt.Sprintf("SET search_path TO %s", config.Schema)).Error; err != nil {
-		return nil, fmt.Errorf("erreur lors de la configuration du schéma: %v", err)
+	// Le search_path PostgreSQL est appliqué à chaque connexion par le DSN ;
+	// le schéma peut être créé avant les migrations
+	if config.CreateSchemaIfMissing {
+		if err := createSchema(db, config.Schema, config.SchemaOwner); err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("erreur lors de la création du schéma: %v", redactError(err, config.Password, config.SSLPassword))
+		}
+	}
+
+	connection := &Connection{db: db, primary: db}
+
+	// Lectures réparties entre les réplicas
+	if len(config.ReplicaHosts) > 0 {
+		connection.db, connection.replicas, err = withReplicas(config, gormConfig, db)
+		if err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("erreur lors de la configuration des réplicas: %v", redactError(err, config.Password, config.SSLPassword))
+		}
+	}
+
+	return connection, nil
+}
+
+// createSchema crée schema s'il n'existe pas, avec owner pour propriétaire
+// s'il est renseigné. MySQL et SQLite n'ont pas de schéma distinct de la base.
+func createSchema(db *gorm.DB, schema, owner string) error {
+	if schema == "" {
+		return nil
 	}
 
-	return &Connection{db: db}, nil
+	switch db.Dialector.Name() {
+	case DriverPostgres:
+		statement := "CREATE SCHEMA IF NOT EXISTS " + quoteIdentifier(schema)
+		if owner != "" {
+			statement += " AUTHORIZATION " + quoteIdentifier(owner)
+		}
+		return db.Exec(statement).Error
+	case DriverSQLServer:
+		// CREATE SCHEMA doit être la seule instruction de son lot
+		statement := "CREATE SCHEMA " + quoteBracket(schema)
+		if owner != "" {
+			statement += " AUTHORIZATION " + quoteBracket(owner)
+		}
+		return db.Exec("IF SCHEMA_ID(?) IS NULL EXEC(?)", schema, statement).Error
+	default:
+		return nil
+	}
+}
+
+// quoteBracket protège un identifiant SQL Server entre crochets
+func quoteBracket(name string) string {
+	return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+}
+
+// openWithRetry ouvre la connexion en retentant tant que l'erreur est
+// transitoire (base en cours de démarrage, hôte pas encore résolu, ...)
+func openWithRetry(config *Config, gormConfig *gorm.Config) (*gorm.DB, error) {
+	delay := connectBackoff
+	for attempt := 1; ; attempt++ {
+		db, err := open(config, gormConfig)
+		if err == nil {
+			return db, nil
+		}
+		// Une tentative qui dépasse ConnectTimeout est elle aussi retentée
+		retryable := IsRetryableError(err) || isStartupError(err) || errors.Is(err, context.DeadlineExceeded)
+		if attempt > config.ConnectRetries || !retryable {
+			return nil, err
+		}
+
+		gormConfig.Logger.Warn(context.Background(),
+			"base de données injoignable (tentative %d/%d): %v, nouvel essai dans %s",
+			attempt, config.ConnectRetries+1, redactError(err, config.Password, config.SSLPassword), delay)
+		time.Sleep(delay)
+
+		delay = min(delay*2, connectMaxBackoff)
+	}
+}
+
+// startupSQLStates sont les codes SQLSTATE d'un serveur PostgreSQL qui
+// démarre ou redémarre : la connexion peut réussir un peu plus tard
+var startupSQLStates = map[string]bool{
+	"57P03": true, // cannot_connect_now (démarrage, récupération)
+	"57P01": true, // admin_shutdown (redémarrage)
 }
 
-// DB retourne l'instance de GORM
+// isStartupError indique si la connexion a été refusée par un serveur
+// PostgreSQL en cours de démarrage ou d'arrêt. Ces erreurs ne sont retentées
+// qu'à la connexion, pas pendant une migration.
+func isStartupError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && startupSQLStates[pgErr.Code]
+}
+
+// open ouvre la connexion et vérifie que la base répond dans le délai
+// Config.ConnectTimeout
+func open(config *Config, gormConfig *gorm.Config) (*gorm.DB, error) {
+	sqlDB, err := config.openDB()
+	if err != nil {
+		return nil, err
+	}
+
+	// gorm.Open interroge déjà la base avec MySQL (SELECT VERSION()). Le pool
+	// de la tentative est fermé quelle que soit l'étape qui échoue, sinon
+	// chaque nouvelle tentative en laisserait un ouvert.
+	db, err := gorm.Open(config.dialector(sqlDB), gormConfig)
+	if err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	ctx := context.Background()
+	if config.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.ConnectTimeout)
+		defer cancel()
+	}
+
+	if err := sqlDB.PingContext(ctx); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// DB retourne l'instance de GORM. Avec des réplicas, les lectures hors
+// transaction sont envoyées aux réplicas et les écritures au primaire.
 func (c *Connection) DB() *gorm.DB {
 	return c.db
 }
 
-// Close ferme la connexion à la base de données
+// Writer retourne l'instance de GORM du primaire, qui n'est jamais redirigée
+// vers un réplica : à utiliser pour les lectures qui doivent voir les
+// écritures qui viennent d'être faites
+func (c *Connection) Writer() *gorm.DB {
+	return c.primary
+}
+
+// Reader retourne une instance de GORM dont toutes les requêtes, lectures
+// brutes comprises, sont envoyées aux réplicas. Sans réplica disponible, elle
+// utilise le primaire.
+func (c *Connection) Reader() *gorm.DB {
+	if c.replicas == nil {
+		return c.db
+	}
+	return c.db.Clauses(dbresolver.Read).Session(&gorm.Session{})
+}
+
+// Close ferme la connexion à la base de données et aux réplicas
 func (c *Connection) Close() error {
-	sqlDB, err := c.db.DB()
+	var errs []error
+	if c.replicas != nil {
+		errs = append(errs, c.replicas.close())
+	}
+
+	sqlDB, err := c.primary.DB()
 	if err != nil {
 		return fmt.Errorf("erreur lors de la récupération de la connexion SQL: %v", err)
 	}
-	return sqlDB.Close()
+	errs = append(errs, sqlDB.Close())
+	return errors.Join(errs...)
 }
@@ -0,0 +1,146 @@
+package gormlib
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials contient les identifiants de connexion à la base de données.
+// Un champ vide conserve la valeur de Config.
+type Credentials struct {
+	User     string
+	Password string
+}
+
+// CredentialProvider fournit les identifiants de connexion. Avec PostgreSQL,
+// il est interrogé avant chaque nouvelle connexion du pool, ce qui permet de
+// prendre en compte des identifiants renouvelés sans redémarrer. Avec les
+// autres pilotes, il n'est interrogé qu'à l'ouverture de la connexion.
+type CredentialProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// EnvCredentialProvider lit les identifiants dans les variables
+// d'environnement DB_USER et DB_PASSWORD
+type EnvCredentialProvider struct{}
+
+// NewEnvCredentialProvider crée un fournisseur d'identifiants lisant
+// l'environnement
+func NewEnvCredentialProvider() *EnvCredentialProvider {
+	return &EnvCredentialProvider{}
+}
+
+func (p *EnvCredentialProvider) Credentials(ctx context.Context) (Credentials, error) {
+	return Credentials{
+		User:     os.Getenv("DB_USER"),
+		Password: os.Getenv("DB_PASSWORD"),
+	}, nil
+}
+
+// FileCredentialProvider lit les identifiants dans des fichiers, relus dès
+// que leur date de modification ou leur taille change. Le contenu de chaque
+// fichier est pris sans le saut de ligne final.
+type FileCredentialProvider struct {
+	UserFile     string // Fichier contenant l'utilisateur, optionnel
+	PasswordFile string // Fichier contenant le mot de passe
+
+	mu    sync.Mutex
+	files map[string]*watchedFile
+}
+
+// watchedFile est le contenu d'un fichier d'identifiants, avec l'état du
+// fichier lors de sa lecture
+type watchedFile struct {
+	modTime time.Time
+	size    int64
+	content string
+}
+
+// NewFileCredentialProvider crée un fournisseur d'identifiants lisant
+// userFile (peut être vide) et passwordFile
+func NewFileCredentialProvider(userFile, passwordFile string) *FileCredentialProvider {
+	return &FileCredentialProvider{
+		UserFile:     userFile,
+		PasswordFile: passwordFile,
+	}
+}
+
+// NewSecretFileCredentialProvider crée un fournisseur d'identifiants lisant
+// les fichiers désignés par DB_USER_FILE et DB_PASSWORD_FILE, comme les
+// secrets Docker. Retourne nil si aucune de ces variables n'est définie.
+func NewSecretFileCredentialProvider() *FileCredentialProvider {
+	userFile, passwordFile := os.Getenv("DB_USER_FILE"), os.Getenv("DB_PASSWORD_FILE")
+	if userFile == "" && passwordFile == "" {
+		return nil
+	}
+	return NewFileCredentialProvider(userFile, passwordFile)
+}
+
+func (p *FileCredentialProvider) Credentials(ctx context.Context) (Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var credentials Credentials
+	var err error
+	if p.UserFile != "" {
+		if credentials.User, err = p.read(p.UserFile); err != nil {
+			return Credentials{}, err
+		}
+	}
+	if p.PasswordFile != "" {
+		if credentials.Password, err = p.read(p.PasswordFile); err != nil {
+			return Credentials{}, err
+		}
+	}
+	return credentials, nil
+}
+
+// read retourne le contenu d'un fichier, relu seulement s'il a changé
+func (p *FileCredentialProvider) read(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("lecture des identifiants: %w", err)
+	}
+
+	if cached, ok := p.files[path]; ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.content, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("lecture des identifiants: %w", err)
+	}
+
+	content := strings.TrimRight(string(data), "\r\n")
+	if p.files == nil {
+		p.files = make(map[string]*watchedFile)
+	}
+	p.files[path] = &watchedFile{modTime: info.ModTime(), size: info.Size(), content: content}
+	return content, nil
+}
+
+// withCredentials retourne une copie de la configuration avec les identifiants
+// du fournisseur, ou la configuration elle-même sans fournisseur
+func (c *Config) withCredentials(ctx context.Context) (*Config, error) {
+	if c.Credentials == nil {
+		return c, nil
+	}
+
+	credentials, err := c.Credentials.Credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := *c
+	if credentials.User != "" {
+		resolved.User = credentials.User
+	}
+	if credentials.Password != "" {
+		resolved.Password = credentials.Password
+	}
+	return &resolved, nil
+}
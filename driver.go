@@ -0,0 +1,213 @@
+package gormlib
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlserver"
+	"gorm.io/gorm"
+)
+
+// Pilotes de base de données supportés
+const (
+	DriverPostgres  = "postgres"
+	DriverMySQL     = "mysql"
+	DriverSQLite    = "sqlite"
+	DriverSQLServer = "sqlserver"
+)
+
+// defaultPort retourne le port par défaut d'un pilote
+func defaultPort(driver string) int {
+	switch driver {
+	case DriverMySQL:
+		return 3306
+	case DriverSQLServer:
+		return 1433
+	case DriverSQLite:
+		return 0
+	default:
+		return 5432
+	}
+}
+
+// defaultSchema retourne le schéma par défaut d'un pilote. MySQL et SQLite
+// n'ont pas de schéma distinct de la base de données.
+func defaultSchema(driver string) string {
+	switch driver {
+	case DriverPostgres:
+		return "public"
+	case DriverSQLServer:
+		return "dbo"
+	default:
+		return ""
+	}
+}
+
+// Dialector retourne le dialecte GORM correspondant au pilote configuré, lié
+// à un nouveau pool de connexions
+func (c *Config) Dialector() (gorm.Dialector, error) {
+	sqlDB, err := c.openDB()
+	if err != nil {
+		return nil, err
+	}
+	return c.dialector(sqlDB), nil
+}
+
+// openDB crée le pool de connexions du pilote configuré. Aucune connexion
+// n'est établie avant la première requête.
+func (c *Config) openDB() (*sql.DB, error) {
+	driver := c.driver()
+	if driver == DriverPostgres {
+		return c.openPostgres()
+	}
+
+	// Hors PostgreSQL, les identifiants ne sont lus qu'une fois
+	c, err := c.withCredentials(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	switch driver {
+	case DriverMySQL, DriverSQLServer:
+		return sql.Open(driver, c.DSN())
+	case DriverSQLite:
+		return sql.Open(sqlite.DriverName, c.DSN())
+	default:
+		return nil, fmt.Errorf("pilote de base de données non supporté: %s", c.Driver)
+	}
+}
+
+// dialector retourne le dialecte GORM du pilote configuré, utilisant conn
+func (c *Config) dialector(conn gorm.ConnPool) gorm.Dialector {
+	switch c.driver() {
+	case DriverMySQL:
+		return mysql.New(mysql.Config{Conn: conn})
+	case DriverSQLite:
+		return &sqlite.Dialector{Conn: conn}
+	case DriverSQLServer:
+		return sqlserver.New(sqlserver.Config{Conn: conn})
+	default:
+		return postgres.New(postgres.Config{Conn: conn})
+	}
+}
+
+// driver retourne le pilote configuré, PostgreSQL par défaut
+func (c *Config) driver() string {
+	if c.Driver == "" {
+		return DriverPostgres
+	}
+	return c.Driver
+}
+
+// postgresDSN retourne la chaîne de connexion PostgreSQL au format
+// clé=valeur de libpq. Les paramètres TLS ne sont ajoutés que s'ils sont
+// renseignés. search_path est transmis à l'ouverture de chaque connexion du
+// pool : c'est la valeur par défaut de la session, que RESET rétablit.
+func (c *Config) postgresDSN() string {
+	params := []struct {
+		key, value string
+		optional   bool
+	}{
+		{"host", c.Host, false},
+		{"port", strconv.Itoa(c.Port), false},
+		{"user", c.User, false},
+		{"password", c.Password, false},
+		{"dbname", c.Database, false},
+		{"search_path", c.searchPath(), true},
+		{"sslmode", c.SSLMode, false},
+		{"sslrootcert", c.SSLRootCert, true},
+		{"sslcert", c.SSLCert, true},
+		{"sslkey", c.SSLKey, true},
+		{"sslpassword", c.SSLPassword, true},
+	}
+
+	var parts []string
+	for _, param := range params {
+		if param.optional && param.value == "" {
+			continue
+		}
+		parts = append(parts, param.key+"="+quoteDSNValue(param.value))
+	}
+	return strings.Join(parts, " ")
+}
+
+// searchPath retourne le search_path correspondant à Config.Schema, avec le
+// schéma entre guillemets doubles pour en conserver la casse
+func (c *Config) searchPath() string {
+	if c.Schema == "" {
+		return ""
+	}
+	return quoteIdentifier(c.Schema)
+}
+
+// quoteIdentifier protège un identifiant SQL entre guillemets doubles
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// unquoteIdentifier retourne le nom désigné par un identifiant SQL : le
+// contenu des guillemets doubles, ou l'identifiant en minuscules s'il n'est
+// pas quoté, comme PostgreSQL l'interprète
+func unquoteIdentifier(identifier string) string {
+	if len(identifier) >= 2 && strings.HasPrefix(identifier, `"`) && strings.HasSuffix(identifier, `"`) {
+		return strings.ReplaceAll(identifier[1:len(identifier)-1], `""`, `"`)
+	}
+	return strings.ToLower(identifier)
+}
+
+// quoteDSNValue protège une valeur de chaîne de connexion libpq : une valeur
+// vide ou contenant des espaces, apostrophes ou barres obliques inverses est
+// placée entre apostrophes, avec ' et \ échappés
+func quoteDSNValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n\r'\\") {
+		return value
+	}
+	return "'" + dsnEscaper.Replace(value) + "'"
+}
+
+// dsnEscaper échappe les caractères spéciaux d'une valeur libpq entre apostrophes
+var dsnEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// mysqlDSN retourne la chaîne de connexion MySQL. parseTime est requis pour
+// lire les colonnes DATETIME dans des time.Time, multiStatements pour exécuter
+// les migrations SQL de plusieurs instructions.
+func (c *Config) mysqlDSN() string {
+	cfg := mysqldriver.NewConfig()
+	cfg.User = c.User
+	cfg.Passwd = c.Password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	cfg.DBName = c.Database
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+	cfg.MultiStatements = true
+	cfg.Params = map[string]string{"charset": "utf8mb4"}
+	return cfg.FormatDSN()
+}
+
+// sqliteDSN retourne le chemin de la base SQLite (":memory:" pour une base en
+// mémoire), avec les clés étrangères activées
+func (c *Config) sqliteDSN() string {
+	return c.Database + "?_pragma=foreign_keys(1)"
+}
+
+// sqlserverDSN retourne l'URL de connexion SQL Server
+func (c *Config) sqlserverDSN() string {
+	u := url.URL{
+		Scheme:   "sqlserver",
+		User:     url.UserPassword(c.User, c.Password),
+		Host:     net.JoinHostPort(c.Host, strconv.Itoa(c.Port)),
+		RawQuery: url.Values{"database": {c.Database}}.Encode(),
+	}
+	return u.String()
+}
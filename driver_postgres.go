@@ -0,0 +1,65 @@
+package gormlib
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// openPostgres crée le pool de connexions PostgreSQL à partir de la
+// configuration pgx afin de pouvoir y appliquer Config.TLSConfig et de lire
+// Config.Credentials avant chaque nouvelle connexion
+func (c *Config) openPostgres() (*sql.DB, error) {
+	connConfig, err := c.pgxConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var options []stdlib.OptionOpenDB
+	if c.Credentials != nil {
+		options = append(options, stdlib.OptionBeforeConnect(c.beforeConnect))
+	}
+
+	return stdlib.OpenDB(*connConfig, options...), nil
+}
+
+// beforeConnect applique les identifiants actuels du fournisseur à une
+// nouvelle connexion
+func (c *Config) beforeConnect(ctx context.Context, connConfig *pgx.ConnConfig) error {
+	credentials, err := c.Credentials.Credentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	if credentials.User != "" {
+		connConfig.User = credentials.User
+	}
+	if credentials.Password != "" {
+		connConfig.Password = credentials.Password
+	}
+	return nil
+}
+
+// pgxConfig retourne la configuration pgx correspondant au DSN, avec
+// Config.TLSConfig le cas échéant
+func (c *Config) pgxConfig() (*pgx.ConnConfig, error) {
+	connConfig, err := pgx.ParseConfig(c.DSN())
+	if err != nil {
+		return nil, err
+	}
+
+	if c.TLSConfig != nil {
+		tlsConfig := c.TLSConfig.Clone()
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = c.Host
+		}
+		connConfig.TLSConfig = tlsConfig
+
+		// Sans repli : la connexion doit utiliser la configuration TLS fournie
+		connConfig.Fallbacks = nil
+	}
+
+	return connConfig, nil
+}
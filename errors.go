@@ -1,27 +1,115 @@
 package gormlib
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
 
-// MigrationError représente une erreur liée aux migrations
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// MigrationError représente une erreur liée aux migrations.
+//
+// errors.Is permet de reconnaître la catégorie de l'erreur à l'aide des
+// erreurs communes ci-dessous (ErrMigrationFailed, ErrRollbackFailed, ...), et
+// errors.As d'atteindre l'erreur sous-jacente, par exemple *pgconn.PgError.
 type MigrationError struct {
-	Op  string // L'opération qui a échoué
-	Err error  // L'erreur sous-jacente
+	Op        string             // L'opération qui a échoué
+	Err       error              // L'erreur sous-jacente
+	Migration string             // La migration concernée, si l'erreur porte sur une migration
+	Direction MigrationDirection // Le sens d'exécution de la migration
+	Attempts  []error            // L'erreur de chaque tentative, lorsque l'opération a été réessayée
+	PgError   *pgconn.PgError    // L'erreur PostgreSQL sous-jacente (SQLSTATE, contrainte, position)
+
+	kind error // L'erreur commune correspondant à la catégorie de l'erreur
 }
 
 func (e *MigrationError) Error() string {
-	return fmt.Sprintf("%s: %v", e.Op, e.Err)
+	msg := e.Op
+	if e.Migration != "" {
+		msg += " " + e.Migration
+		if e.Direction != "" {
+			msg += " (" + string(e.Direction) + ")"
+		}
+	}
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.Err)
+	}
+	if len(e.Attempts) > 1 {
+		msg = fmt.Sprintf("%s (%d tentatives)", msg, len(e.Attempts))
+	}
+	return msg
+}
+
+// Unwrap retourne l'erreur sous-jacente
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// Is indique si l'erreur appartient à la catégorie de l'erreur commune target
+func (e *MigrationError) Is(target error) bool {
+	return e.kind != nil && e.kind == target
+}
+
+// SQLState retourne le code SQLSTATE de l'erreur PostgreSQL sous-jacente, ou
+// une chaîne vide
+func (e *MigrationError) SQLState() string {
+	if e.PgError == nil {
+		return ""
+	}
+	return e.PgError.Code
 }
 
-// NewMigrationError crée une nouvelle erreur de migration
+// NewMigrationError crée une nouvelle erreur de migration. Les erreurs des
+// opérations "run migration" et "rollback migration" correspondent
+// respectivement à ErrMigrationFailed et ErrRollbackFailed.
 func NewMigrationError(op string, err error) error {
-	return &MigrationError{Op: op, Err: err}
+	return newMigrationError(operationKind(op), op, err)
+}
+
+// operationKind retourne l'erreur commune correspondant à une opération
+func operationKind(op string) error {
+	switch op {
+	case "run migration":
+		return ErrMigrationFailed
+	case "rollback migration":
+		return ErrRollbackFailed
+	}
+	return nil
+}
+
+// newMigrationError crée une erreur de migration de la catégorie kind
+func newMigrationError(kind error, op string, err error) *MigrationError {
+	e := &MigrationError{Op: op, Err: err, kind: kind}
+	e.setCause(err)
+	return e
+}
+
+// migrationNotFound construit l'erreur renvoyée lors du rollback d'une
+// migration qui n'a pas été appliquée
+func migrationNotFound(migration Migration) *MigrationError {
+	e := newMigrationError(ErrMigrationNotFound, "rollback migration", fmt.Errorf("migration non appliquée"))
+	e.Migration = migration.Name()
+	e.Direction = DirectionDown
+	return e
+}
+
+// setCause renseigne l'erreur sous-jacente et l'erreur PostgreSQL qu'elle contient
+func (e *MigrationError) setCause(err error) {
+	e.Err = err
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		e.PgError = pgErr
+	}
 }
 
 // Common migration errors
 var (
-	ErrMigrationNotFound     = NewMigrationError("migration not found", nil)
-	ErrMigrationAlreadyExists = NewMigrationError("migration already exists", nil)
-	ErrInvalidMigrationName  = NewMigrationError("invalid migration name", nil)
-	ErrMigrationFailed      = NewMigrationError("migration failed", nil)
-	ErrRollbackFailed       = NewMigrationError("rollback failed", nil)
-) 
\ No newline at end of file
+	ErrMigrationNotFound      error = &MigrationError{Op: "migration not found"}
+	ErrMigrationAlreadyExists error = &MigrationError{Op: "migration already exists"}
+	ErrInvalidMigrationName   error = &MigrationError{Op: "invalid migration name"}
+	ErrMigrationFailed        error = &MigrationError{Op: "migration failed"}
+	ErrRollbackFailed         error = &MigrationError{Op: "rollback failed"}
+	ErrMigrationLocked        error = &MigrationError{Op: "migration locked"}
+	ErrChecksumMismatch       error = &MigrationError{Op: "checksum mismatch"}
+	ErrNoSchemaChanges        error = &MigrationError{Op: "no schema changes"}
+)
@@ -0,0 +1,132 @@
+package gormlib
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout est le délai maximum du ping effectué par /readyz
+const readinessTimeout = 2 * time.Second
+
+// HealthStatus décrit l'état de la connexion à la base de données
+type HealthStatus struct {
+	Healthy bool          // La base a répondu au ping
+	Latency time.Duration // Durée du ping
+	Pool    sql.DBStats   // Statistiques du pool de connexions
+	Err     error         // L'erreur du ping, si la base n'a pas répondu
+}
+
+// Ping vérifie que la base de données répond et retourne la durée de l'aller-retour
+func (c *Connection) Ping(ctx context.Context) (time.Duration, error) {
+	sqlDB, err := c.db.DB()
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	err = sqlDB.PingContext(ctx)
+	return time.Since(start), err
+}
+
+// HealthCheck vérifie que la base de données répond et retourne la latence et
+// les statistiques du pool de connexions
+func (c *Connection) HealthCheck(ctx context.Context) HealthStatus {
+	sqlDB, err := c.db.DB()
+	if err != nil {
+		return HealthStatus{Err: err}
+	}
+
+	latency, err := c.Ping(ctx)
+	return HealthStatus{
+		Healthy: err == nil,
+		Latency: latency,
+		Pool:    sqlDB.Stats(),
+		Err:     err,
+	}
+}
+
+// HealthHandler retourne un http.Handler qui répond sur /healthz et /readyz.
+//
+// /healthz indique que le processus est vivant et expose les statistiques du
+// pool sans interroger la base, afin qu'une panne de la base ne provoque pas
+// le redémarrage du service. /readyz interroge la base et répond 503 si elle
+// ne répond pas.
+func (c *Connection) HealthHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		sqlDB, err := c.db.DB()
+		if err != nil {
+			writeHealth(w, http.StatusServiceUnavailable, HealthStatus{Err: err})
+			return
+		}
+		writeHealth(w, http.StatusOK, HealthStatus{Healthy: true, Pool: sqlDB.Stats()})
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		status := c.HealthCheck(ctx)
+		code := http.StatusOK
+		if !status.Healthy {
+			code = http.StatusServiceUnavailable
+		}
+		writeHealth(w, code, status)
+	})
+
+	return mux
+}
+
+// healthResponse est la représentation JSON d'un HealthStatus
+type healthResponse struct {
+	Status  string       `json:"status"`
+	Latency string       `json:"latency,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Pool    poolResponse `json:"pool"`
+}
+
+// poolResponse est la représentation JSON des statistiques du pool
+type poolResponse struct {
+	MaxOpenConnections int    `json:"max_open_connections"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDuration       string `json:"wait_duration"`
+	MaxIdleClosed      int64  `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64  `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64  `json:"max_lifetime_closed"`
+}
+
+// writeHealth écrit un HealthStatus au format JSON
+func writeHealth(w http.ResponseWriter, code int, status HealthStatus) {
+	response := healthResponse{
+		Status: "ok",
+		Pool: poolResponse{
+			MaxOpenConnections: status.Pool.MaxOpenConnections,
+			OpenConnections:    status.Pool.OpenConnections,
+			InUse:              status.Pool.InUse,
+			Idle:               status.Pool.Idle,
+			WaitCount:          status.Pool.WaitCount,
+			WaitDuration:       status.Pool.WaitDuration.String(),
+			MaxIdleClosed:      status.Pool.MaxIdleClosed,
+			MaxIdleTimeClosed:  status.Pool.MaxIdleTimeClosed,
+			MaxLifetimeClosed:  status.Pool.MaxLifetimeClosed,
+		},
+	}
+	if status.Latency > 0 {
+		response.Latency = status.Latency.String()
+	}
+	if status.Err != nil {
+		response.Status = "error"
+		response.Error = status.Err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(response)
+}
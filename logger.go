@@ -0,0 +1,153 @@
+package gormlib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
+)
+
+// Niveaux de journalisation des requêtes SQL
+const (
+	LogLevelSilent = "silent"
+	LogLevelError  = "error"
+	LogLevelWarn   = "warn"
+	LogLevelInfo   = "info"
+)
+
+// ParseLogLevel convertit un niveau de journalisation (silent, error, warn ou
+// info) en niveau GORM
+func ParseLogLevel(level string) (logger.LogLevel, error) {
+	switch strings.ToLower(level) {
+	case LogLevelSilent:
+		return logger.Silent, nil
+	case LogLevelError:
+		return logger.Error, nil
+	case "", LogLevelWarn:
+		return logger.Warn, nil
+	case LogLevelInfo:
+		return logger.Info, nil
+	default:
+		return logger.Warn, fmt.Errorf("niveau de journalisation inconnu: %s", level)
+	}
+}
+
+// gormLogger retourne le logger GORM correspondant à la configuration : slog
+// si Config.Logger est défini, la sortie standard sinon
+func (c *Config) gormLogger() logger.Interface {
+	level, _ := ParseLogLevel(c.LogLevel)
+	config := logger.Config{
+		SlowThreshold:             c.SlowThreshold,
+		LogLevel:                  level,
+		ParameterizedQueries:      c.RedactParams,
+		IgnoreRecordNotFoundError: true,
+		Colorful:                  false,
+	}
+
+	if c.Logger != nil {
+		return NewSlogLogger(c.Logger, config)
+	}
+	return logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), config)
+}
+
+// SlogLogger est un logger GORM qui écrit des entrées structurées dans un
+// *slog.Logger. Chaque requête est journalisée avec sa durée, le nombre de
+// lignes affectées et l'appelant.
+type SlogLogger struct {
+	logger *slog.Logger
+	config logger.Config
+}
+
+// NewSlogLogger crée un logger GORM écrivant dans l. config.LogLevel,
+// config.SlowThreshold, config.ParameterizedQueries et
+// config.IgnoreRecordNotFoundError sont pris en compte.
+func NewSlogLogger(l *slog.Logger, config logger.Config) *SlogLogger {
+	return &SlogLogger{logger: l, config: config}
+}
+
+// LogMode retourne une copie du logger avec le niveau level
+func (l *SlogLogger) LogMode(level logger.LogLevel) logger.Interface {
+	clone := *l
+	clone.config.LogLevel = level
+	return &clone
+}
+
+func (l *SlogLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.config.LogLevel >= logger.Info {
+		l.logger.InfoContext(ctx, fmt.Sprintf(msg, data...), slog.String("caller", utils.FileWithLineNum()))
+	}
+}
+
+func (l *SlogLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.config.LogLevel >= logger.Warn {
+		l.logger.WarnContext(ctx, fmt.Sprintf(msg, data...), slog.String("caller", utils.FileWithLineNum()))
+	}
+}
+
+func (l *SlogLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.config.LogLevel >= logger.Error {
+		l.logger.ErrorContext(ctx, fmt.Sprintf(msg, data...), slog.String("caller", utils.FileWithLineNum()))
+	}
+}
+
+// Trace journalise une requête SQL : en erreur, lente (au-delà de
+// SlowThreshold) ou, au niveau info, toutes les requêtes
+func (l *SlogLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.config.LogLevel <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	slow := l.config.SlowThreshold > 0 && elapsed > l.config.SlowThreshold
+
+	var level slog.Level
+	var msg string
+	switch {
+	case err != nil && l.config.LogLevel >= logger.Error &&
+		!(l.config.IgnoreRecordNotFoundError && errors.Is(err, gorm.ErrRecordNotFound)):
+		level, msg = slog.LevelError, "requête SQL en erreur"
+	case slow && l.config.LogLevel >= logger.Warn:
+		level, msg = slog.LevelWarn, "requête SQL lente"
+	case l.config.LogLevel >= logger.Info:
+		level, msg = slog.LevelInfo, "requête SQL"
+	default:
+		return
+	}
+
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Duration("duration", elapsed),
+		slog.Int64("rows", rows),
+		slog.String("caller", utils.FileWithLineNum()),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	if slow {
+		attrs = append(attrs, slog.Duration("slow_threshold", l.config.SlowThreshold))
+	}
+
+	l.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// ParamsFilter retire la valeur des paramètres des requêtes journalisées
+// lorsque ParameterizedQueries est activé
+func (l *SlogLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if l.config.ParameterizedQueries {
+		return sql, nil
+	}
+	return sql, params
+}
@@ -13,9 +13,82 @@ type Migration interface {
 	Name() string
 }
 
+// MigrationWithChecksum est implémentée par les migrations qui fournissent
+// leur propre somme de contrôle. Elle permet de détecter une migration
+// modifiée après son application.
+type MigrationWithChecksum interface {
+	Migration
+	Checksum() string
+}
+
+// NonTransactionalMigration est implémentée par les migrations qui ne peuvent
+// pas s'exécuter dans une transaction (CREATE INDEX CONCURRENTLY,
+// ALTER TYPE ... ADD VALUE, VACUUM, ...). Lorsque NoTransaction retourne true,
+// la migration s'exécute sur une connexion dédiée, hors transaction, et est
+// enregistrée dès qu'elle a réussi.
+type NonTransactionalMigration interface {
+	Migration
+	NoTransaction() bool
+}
+
+// MigrationWithTimeout est implémentée par les migrations qui ont leur propre
+// délai d'exécution, à la place de MigrationConfig.MigrationTimeout
+type MigrationWithTimeout interface {
+	Migration
+	Timeout() time.Duration
+}
+
 // MigrationRecord représente une migration appliquée dans la base de données
 type MigrationRecord struct {
 	ID        uint      `gorm:"primaryKey"`
-	Name      string    `gorm:"uniqueIndex;not null"`
+	Name      string    `gorm:"size:255;uniqueIndex;not null"` // Taille bornée pour l'index unique sous MySQL et SQL Server
 	AppliedAt time.Time `gorm:"not null"`
-} 
\ No newline at end of file
+	Checksum  string    `gorm:"size:64"`
+}
+
+// fileMigration associe une migration à la somme de contrôle de son fichier source
+type fileMigration struct {
+	Migration
+	checksum string
+}
+
+// Checksum retourne la somme de contrôle de la migration, ou celle du
+// fichier source si la migration n'en fournit pas
+func (f *fileMigration) Checksum() string {
+	if c, ok := f.Migration.(MigrationWithChecksum); ok {
+		return c.Checksum()
+	}
+	return f.checksum
+}
+
+// Unwrap retourne la migration d'origine
+func (f *fileMigration) Unwrap() Migration {
+	return f.Migration
+}
+
+// asMigration recherche, en remontant les migrations enveloppées (fichiers
+// découverts, ...), une migration qui implémente T
+func asMigration[T any](migration Migration) (T, bool) {
+	for migration != nil {
+		if t, ok := migration.(T); ok {
+			return t, true
+		}
+		wrapper, ok := migration.(interface{ Unwrap() Migration })
+		if !ok {
+			break
+		}
+		migration = wrapper.Unwrap()
+	}
+
+	var zero T
+	return zero, false
+}
+
+// migrationChecksum retourne la somme de contrôle d'une migration, ou une
+// chaîne vide si elle n'en a pas
+func migrationChecksum(migration Migration) string {
+	if c, ok := migration.(MigrationWithChecksum); ok {
+		return c.Checksum()
+	}
+	return ""
+}
@@ -0,0 +1,858 @@
+package gormlib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/format"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const autogenTemplate = `package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// %s représente la migration %s, générée par -autogen
+// pour %s à partir des modèles %s
+type %s struct{}
+
+// Up effectue la migration
+func (m *%s) Up(db *gorm.DB) error {
+	for _, statement := range []string{
+%s	} {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Down effectue le rollback
+func (m *%s) Down(db *gorm.DB) error {
+	for _, statement := range []string{
+%s	} {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Name retourne le nom de la migration
+func (m *%s) Name() string {
+	return "%s"
+}
+`
+
+// fullDataTypeSize extrait la taille d'un type SQL ("varchar(255)"), comme
+// la migration automatique de GORM
+var fullDataTypeSize = regexp.MustCompile(`\D*(\d+)\D?`)
+
+// WithDB définit la base dont le schéma est comparé aux modèles par
+// GenerateFromModels
+func (g *MigrationGenerator) WithDB(db *gorm.DB) *MigrationGenerator {
+	// Le schéma de référence est celui du primaire
+	g.db = primaryDB(db)
+	return g
+}
+
+// GenerateFromModels compare les modèles GORM au schéma de la base définie
+// par WithDB et crée une migration Go dont Up applique les différences
+// (tables, colonnes, types, nullabilité, valeurs par défaut, index et clés
+// étrangères) et Down les annule. Le SQL est écrit explicitement pour le
+// pilote de la base ; les instructions qui détruisent des données sont
+// précédées d'un commentaire DESTRUCTIF.
+//
+// Les tables absentes des modèles ne sont jamais supprimées, les modèles ne
+// décrivant pas forcément toute la base. Retourne ErrNoSchemaChanges si la
+// base est à jour.
+func (g *MigrationGenerator) GenerateFromModels(name string, models ...any) error {
+	if g.db == nil {
+		return NewMigrationError("generate from models", errors.New("aucune base de données à comparer, voir WithDB"))
+	}
+	if len(models) == 0 {
+		return NewMigrationError("generate from models", errors.New("aucun modèle à comparer"))
+	}
+
+	db, err := unqualifiedDB(g.db)
+	if err != nil {
+		return NewMigrationError("generate from models", err)
+	}
+	diff := &schemaDiff{ctx: context.Background(), db: db}
+	changes, tables, err := diff.compare(models)
+	if err != nil {
+		return NewMigrationError("generate from models", err)
+	}
+	if len(changes) == 0 {
+		return ErrNoSchemaChanges
+	}
+
+	migrationName, structName, filePath, err := g.prepareMigrationFile(name)
+	if err != nil {
+		return err
+	}
+
+	// Down annule les changements dans l'ordre inverse de Up
+	var up, down strings.Builder
+	for _, change := range changes {
+		change.write(&up, change.up, change.upWarning)
+	}
+	for _, change := range slices.Backward(changes) {
+		change.write(&down, change.down, change.downWarning)
+	}
+
+	content := fmt.Sprintf(autogenTemplate,
+		structName, name, g.db.Dialector.Name(), strings.Join(tables, ", "), structName,
+		structName, up.String(), structName, down.String(), structName, migrationName)
+	source, err := format.Source([]byte(content))
+	if err != nil {
+		return NewMigrationError("format migration file", err)
+	}
+
+	if err := os.WriteFile(filePath, source, DefaultFileMode); err != nil {
+		return NewMigrationError("create migration file", err)
+	}
+
+	// Mettre à jour l'index des migrations
+	return g.GenerateRegistry()
+}
+
+// unqualifiedDB retourne, pour PostgreSQL, une instance de GORM sur le pool
+// de db dont les tables ne sont pas préfixées par Config.Schema. Le SQL généré
+// ne nomme alors pas le schéma et s'applique à celui du search_path : le
+// schéma de la connexion, ou celui de chaque locataire avec TenantMigrator.
+func unqualifiedDB(db *gorm.DB) (*gorm.DB, error) {
+	namer, ok := db.NamingStrategy.(schema.NamingStrategy)
+	if db.Dialector.Name() != DriverPostgres || !ok || !strings.HasSuffix(namer.TablePrefix, ".") {
+		return db, nil
+	}
+
+	namer.TablePrefix = ""
+	return gorm.Open(postgres.New(postgres.Config{Conn: db.ConnPool}), &gorm.Config{
+		Logger:               db.Logger,
+		NowFunc:              db.NowFunc,
+		NamingStrategy:       namer,
+		DisableAutomaticPing: true,
+	})
+}
+
+// schemaChange est une différence entre les modèles et la base, avec les
+// instructions qui l'appliquent et celles qui l'annulent
+type schemaChange struct {
+	comment     string
+	up, down    []string
+	upWarning   string // Données perdues par up, vide si aucune
+	downWarning string // Données perdues par down, vide si aucune
+}
+
+// write écrit le commentaire et les instructions d'un sens du changement,
+// comme éléments d'un littéral []string
+func (c schemaChange) write(b *strings.Builder, statements []string, warning string) {
+	fmt.Fprintf(b, "// %s\n", c.comment)
+	if warning != "" && len(statements) > 0 {
+		fmt.Fprintf(b, "// DESTRUCTIF : %s\n", warning)
+	}
+	for _, statement := range statements {
+		b.WriteString(goStringLiteral(statement) + ",\n")
+	}
+}
+
+// goStringLiteral retourne s sous forme de chaîne Go, brute si possible
+func goStringLiteral(s string) string {
+	if strings.Contains(s, "`") || strings.ContainsRune(s, '\r') {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
+
+// schemaDiff compare des modèles GORM au schéma d'une base
+type schemaDiff struct {
+	ctx context.Context
+	db  *gorm.DB
+
+	// Changements regroupés dans l'ordre où Up les applique : les colonnes
+	// existent avant leurs index et les suppressions viennent en dernier
+	created, added, altered, indexes, constraints, dropped []schemaChange
+}
+
+// compare retourne les changements qui amènent la base au niveau des modèles
+// et les tables des modèles
+func (d *schemaDiff) compare(models []any) ([]schemaChange, []string, error) {
+	var tables []string
+	var missing []any
+	seen := make(map[string]bool)
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: d.db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, nil, fmt.Errorf("modèle %T: %w", model, err)
+		}
+		if seen[stmt.Schema.Table] {
+			continue
+		}
+		seen[stmt.Schema.Table] = true
+		tables = append(tables, stmt.Schema.Table)
+
+		if !d.db.Migrator().HasTable(model) {
+			missing = append(missing, model)
+			continue
+		}
+		if err := d.compareTable(model, stmt.Schema); err != nil {
+			return nil, nil, fmt.Errorf("table %s: %w", stmt.Schema.Table, err)
+		}
+	}
+
+	if err := d.createTables(missing); err != nil {
+		return nil, nil, err
+	}
+
+	changes := slices.Concat(d.created, d.added, d.altered, d.indexes, d.constraints, d.dropped)
+	return changes, tables, nil
+}
+
+// capture retourne les instructions générées par fn sans les exécuter
+func (d *schemaDiff) capture(fn func(tx *gorm.DB) error) ([]string, error) {
+	return captureStatements(d.ctx, d.db, fn)
+}
+
+// createTables crée les tables absentes de la base, les tables référencées
+// par une clé étrangère en premier
+func (d *schemaDiff) createTables(models []any) error {
+	models, err := d.orderByDependencies(models)
+	if err != nil {
+		return err
+	}
+
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: d.db}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		table := stmt.Schema.Table
+
+		up, err := d.capture(func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(model)
+		})
+		if err != nil {
+			return fmt.Errorf("table %s: %w", table, err)
+		}
+		down, err := d.capture(func(tx *gorm.DB) error {
+			return tx.Exec("DROP TABLE ?", clause.Table{Name: table}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("table %s: %w", table, err)
+		}
+
+		d.created = append(d.created, schemaChange{
+			comment:     fmt.Sprintf("Table %s : nouvelle table", table),
+			up:          up,
+			down:        down,
+			downWarning: fmt.Sprintf("supprime la table %s et ses données", table),
+		})
+	}
+	return nil
+}
+
+// orderByDependencies trie les modèles pour que chaque table soit créée après
+// les tables qu'elle référence. Les références circulaires gardent l'ordre
+// d'origine.
+func (d *schemaDiff) orderByDependencies(models []any) ([]any, error) {
+	schemas := make(map[any]*schema.Schema, len(models))
+	tables := make(map[string]bool, len(models))
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: d.db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, err
+		}
+		schemas[model] = stmt.Schema
+		tables[stmt.Schema.Table] = true
+	}
+
+	// ready indique si les tables référencées par le modèle sont créées ou
+	// ne font pas partie des tables à créer
+	ready := func(s *schema.Schema) bool {
+		for _, rel := range s.Relationships.Relations {
+			if constraint := rel.ParseConstraint(); constraint != nil && constraint.Schema == s &&
+				constraint.ReferenceSchema != s && tables[constraint.ReferenceSchema.Table] {
+				return false
+			}
+		}
+		return true
+	}
+
+	ordered := make([]any, 0, len(models))
+	for len(models) > 0 {
+		remaining := models[:0:0]
+		for _, model := range models {
+			if ready(schemas[model]) {
+				ordered = append(ordered, model)
+				delete(tables, schemas[model].Table)
+			} else {
+				remaining = append(remaining, model)
+			}
+		}
+		if len(remaining) == len(models) {
+			ordered = append(ordered, remaining...)
+			break
+		}
+		models = remaining
+	}
+	return ordered, nil
+}
+
+// compareTable compare les colonnes, les index et les clés étrangères d'une
+// table existante à son modèle
+func (d *schemaDiff) compareTable(model any, s *schema.Schema) error {
+	columnTypes, err := d.db.Migrator().ColumnTypes(model)
+	if err != nil {
+		return err
+	}
+	columns := make(map[string]gorm.ColumnType, len(columnTypes))
+	for _, column := range columnTypes {
+		columns[strings.ToLower(column.Name())] = column
+	}
+
+	fields := make(map[string]bool, len(s.DBNames))
+	for _, dbName := range s.DBNames {
+		field := s.FieldsByDBName[dbName]
+		if field == nil || field.IgnoreMigration {
+			continue
+		}
+		fields[strings.ToLower(dbName)] = true
+
+		column, exists := columns[strings.ToLower(dbName)]
+		if !exists {
+			err = d.addColumn(model, s.Table, field)
+		} else {
+			err = d.alterColumn(s.Table, field, column)
+		}
+		if err != nil {
+			return fmt.Errorf("colonne %s: %w", dbName, err)
+		}
+	}
+
+	for _, column := range columnTypes {
+		if !fields[strings.ToLower(column.Name())] {
+			if err := d.dropColumn(s.Table, column); err != nil {
+				return fmt.Errorf("colonne %s: %w", column.Name(), err)
+			}
+		}
+	}
+
+	if err := d.compareIndexes(model, s); err != nil {
+		return err
+	}
+	return d.compareConstraints(model, s)
+}
+
+// addColumn ajoute une colonne du modèle absente de la table
+func (d *schemaDiff) addColumn(model any, table string, field *schema.Field) error {
+	up, err := d.capture(func(tx *gorm.DB) error {
+		return tx.Migrator().AddColumn(model, field.DBName)
+	})
+	if err != nil {
+		return err
+	}
+	down, err := d.capture(func(tx *gorm.DB) error {
+		return tx.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: table}, clause.Column{Name: field.DBName}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	d.added = append(d.added, schemaChange{
+		comment:     fmt.Sprintf("Table %s : nouvelle colonne %s", table, field.DBName),
+		up:          up,
+		down:        down,
+		downWarning: fmt.Sprintf("supprime la colonne %s et ses données", field.DBName),
+	})
+	return nil
+}
+
+// dropColumn supprime une colonne absente du modèle. Down la recrée avec
+// son type actuel, sans ses données.
+func (d *schemaDiff) dropColumn(table string, column gorm.ColumnType) error {
+	up, err := d.capture(func(tx *gorm.DB) error {
+		return tx.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: table}, clause.Column{Name: column.Name()}).Error
+	})
+	if err != nil {
+		return err
+	}
+	down, err := d.capture(func(tx *gorm.DB) error {
+		return tx.Exec("ALTER TABLE ? ADD ? ?",
+			clause.Table{Name: table}, clause.Column{Name: column.Name()}, clause.Expr{SQL: d.liveColumn(column).definition()}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	d.dropped = append(d.dropped, schemaChange{
+		comment:   fmt.Sprintf("Table %s : colonne %s absente du modèle (Down la recrée vide)", table, column.Name()),
+		up:        up,
+		down:      down,
+		upWarning: fmt.Sprintf("supprime la colonne %s et ses données", column.Name()),
+	})
+	return nil
+}
+
+// columnDefinition est le type, la nullabilité et la valeur par défaut d'une
+// colonne, dans le modèle ou dans la base
+type columnDefinition struct {
+	dataType     string
+	notNull      bool
+	defaultValue string // Expression SQL, vide sans valeur par défaut
+}
+
+// definition retourne la définition SQL de la colonne
+func (c columnDefinition) definition() string {
+	definition := c.dataType
+	if c.notNull {
+		definition += " NOT NULL"
+	}
+	if c.defaultValue != "" {
+		definition += " DEFAULT " + c.defaultValue
+	}
+	return definition
+}
+
+// describe retourne la définition de la colonne pour un commentaire
+func (c columnDefinition) describe() string {
+	if !c.notNull {
+		return c.definition() + " NULL"
+	}
+	return c.definition()
+}
+
+// modelColumn retourne la définition d'une colonne d'après son champ, comme
+// la construit le Migrator de GORM
+func (d *schemaDiff) modelColumn(field *schema.Field) columnDefinition {
+	column := columnDefinition{
+		dataType: d.db.Dialector.DataTypeOf(field),
+		notNull:  field.NotNull,
+	}
+	if field.HasDefaultValue && field.DefaultValueInterface != nil {
+		stmt := &gorm.Statement{Vars: []any{field.DefaultValueInterface}}
+		d.db.Dialector.BindVarTo(stmt, stmt, field.DefaultValueInterface)
+		column.defaultValue = d.db.Dialector.Explain(stmt.SQL.String(), field.DefaultValueInterface)
+	} else if field.HasDefaultValue && field.DefaultValue != "" && field.DefaultValue != "(-)" && !strings.EqualFold(field.DefaultValue, "NULL") {
+		column.defaultValue = field.DefaultValue
+	}
+	return column
+}
+
+// liveColumn retourne la définition d'une colonne de la base
+func (d *schemaDiff) liveColumn(column gorm.ColumnType) columnDefinition {
+	definition := columnDefinition{dataType: d.liveDataType(column)}
+	if nullable, ok := column.Nullable(); ok {
+		definition.notNull = !nullable
+	}
+	if value, ok := column.DefaultValue(); ok {
+		definition.defaultValue = defaultLiteral(value)
+	}
+	return definition
+}
+
+// liveDataType retourne le type SQL d'une colonne de la base, avec sa taille
+// ou sa précision
+func (d *schemaDiff) liveDataType(column gorm.ColumnType) string {
+	if dataType, ok := column.ColumnType(); ok && dataType != "" {
+		return dataType
+	}
+
+	dataType := column.DatabaseTypeName()
+	lower := strings.ToLower(dataType)
+	switch {
+	case strings.Contains(lower, "char") || strings.Contains(lower, "binary"):
+		if length, ok := column.Length(); ok && length > 0 {
+			return fmt.Sprintf("%s(%d)", dataType, length)
+		} else if ok && length < 0 && d.db.Dialector.Name() == DriverSQLServer {
+			return dataType + "(MAX)"
+		}
+	case lower == "numeric" || lower == "decimal":
+		if precision, scale, ok := column.DecimalSize(); ok && precision > 0 {
+			return fmt.Sprintf("%s(%d,%d)", dataType, precision, scale)
+		}
+	}
+	return dataType
+}
+
+// defaultLiteral retourne l'expression SQL d'une valeur par défaut lue dans
+// la base. PostgreSQL la retourne sans guillemets ni conversion de type : les
+// valeurs qui ne sont ni des nombres, ni des mots-clés, ni des appels de
+// fonction sont traitées comme du texte.
+func defaultLiteral(value string) string {
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value
+	}
+	switch strings.ToUpper(value) {
+	case "NULL", "TRUE", "FALSE", "CURRENT_TIMESTAMP", "CURRENT_DATE", "CURRENT_TIME":
+		return value
+	}
+	if strings.Contains(value, "(") || (len(value) >= 2 && strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'")) {
+		return value
+	}
+	return quoteLiteral(value)
+}
+
+// columnDiff indique ce qui diffère entre une colonne et son champ
+type columnDiff struct {
+	dataType, nullable, defaultValue bool
+}
+
+func (c columnDiff) changed() bool {
+	return c.dataType || c.nullable || c.defaultValue
+}
+
+// compareColumn compare une colonne à son champ selon les règles de la
+// migration automatique de GORM, sans ignorer le passage de NOT NULL à NULL
+func (d *schemaDiff) compareColumn(field *schema.Field, column gorm.ColumnType) (diff columnDiff) {
+	fullDataType := strings.TrimSpace(strings.ToLower(d.db.Migrator().FullDataTypeOf(field).SQL))
+	realDataType := strings.ToLower(column.DatabaseTypeName())
+	isSameType := fullDataType == realDataType
+
+	// Type
+	if !field.PrimaryKey && !strings.HasPrefix(fullDataType, realDataType) {
+		for _, alias := range d.db.Migrator().GetTypeAliases(realDataType) {
+			if strings.HasPrefix(fullDataType, alias) {
+				isSameType = true
+				break
+			}
+		}
+		if !isSameType {
+			diff.dataType = true
+		}
+	}
+
+	if !isSameType {
+		// Taille
+		if length, ok := column.Length(); length != int64(field.Size) {
+			if length > 0 && field.Size > 0 {
+				diff.dataType = true
+			} else if matches := fullDataTypeSize.FindAllStringSubmatch(fullDataType, -1); !field.PrimaryKey &&
+				len(matches) == 1 && matches[0][1] != fmt.Sprint(length) && ok {
+				diff.dataType = true
+			}
+		}
+
+		// Précision
+		if precision, _, ok := column.DecimalSize(); ok && int64(field.Precision) != precision {
+			if regexp.MustCompile(fmt.Sprintf("[^0-9]%d[^0-9]", field.Precision)).MatchString(d.db.Dialector.DataTypeOf(field)) {
+				diff.dataType = true
+			}
+		}
+	}
+
+	// Nullabilité, la clé primaire étant toujours NOT NULL
+	if nullable, ok := column.Nullable(); ok && nullable == field.NotNull && !field.PrimaryKey {
+		diff.nullable = true
+	}
+
+	// Valeur par défaut
+	if !field.PrimaryKey {
+		currentDefaultNotNull := field.HasDefaultValue && (field.DefaultValueInterface != nil || !strings.EqualFold(field.DefaultValue, "NULL"))
+		dv, dvNotNull := column.DefaultValue()
+		switch {
+		case dvNotNull != currentDefaultNotNull:
+			diff.defaultValue = true
+		case currentDefaultNotNull || dvNotNull:
+			switch field.GORMDataType {
+			case schema.Time:
+				diff.defaultValue = !strings.EqualFold(strings.TrimSuffix(dv, "()"), strings.TrimSuffix(field.DefaultValue, "()"))
+			case schema.Bool:
+				v1, _ := strconv.ParseBool(dv)
+				v2, _ := strconv.ParseBool(field.DefaultValue)
+				diff.defaultValue = v1 != v2
+			default:
+				diff.defaultValue = dv != field.DefaultValue
+			}
+		}
+	}
+	return diff
+}
+
+// alterColumn modifie une colonne dont le type, la nullabilité ou la valeur
+// par défaut diffère du champ
+func (d *schemaDiff) alterColumn(table string, field *schema.Field, column gorm.ColumnType) error {
+	diff := d.compareColumn(field, column)
+	if !diff.changed() {
+		return nil
+	}
+
+	from, to := d.liveColumn(column), d.modelColumn(field)
+	change := schemaChange{
+		comment: fmt.Sprintf("Table %s : colonne %s modifiée (%s -> %s)", table, field.DBName, from.describe(), to.describe()),
+	}
+
+	var err error
+	var note string
+	if change.up, note, err = d.alterStatements(table, field.DBName, to, diff); err != nil {
+		return err
+	}
+	if change.down, _, err = d.alterStatements(table, field.DBName, from, diff); err != nil {
+		return err
+	}
+	if note != "" {
+		change.comment += ", " + note
+	}
+	if diff.dataType {
+		change.upWarning = "le changement de type peut tronquer ou rejeter les données existantes"
+		change.downWarning = change.upWarning
+	}
+
+	d.altered = append(d.altered, change)
+	return nil
+}
+
+// alterStatements retourne les instructions qui donnent à une colonne la
+// définition to, et une note pour ce que le pilote ne sait pas modifier
+func (d *schemaDiff) alterStatements(table, name string, to columnDefinition, diff columnDiff) ([]string, string, error) {
+	var note string
+	statements, err := d.capture(func(tx *gorm.DB) error {
+		table, column := clause.Table{Name: table}, clause.Column{Name: name}
+		switch d.db.Dialector.Name() {
+		case DriverPostgres:
+			if diff.dataType {
+				dataType := clause.Expr{SQL: to.dataType}
+				if err := tx.Exec("ALTER TABLE ? ALTER COLUMN ? TYPE ? USING ?::?", table, column, dataType, column, dataType).Error; err != nil {
+					return err
+				}
+			}
+			if diff.nullable {
+				action := "DROP NOT NULL"
+				if to.notNull {
+					action = "SET NOT NULL"
+				}
+				if err := tx.Exec("ALTER TABLE ? ALTER COLUMN ? "+action, table, column).Error; err != nil {
+					return err
+				}
+			}
+			if diff.defaultValue {
+				if to.defaultValue == "" {
+					return tx.Exec("ALTER TABLE ? ALTER COLUMN ? DROP DEFAULT", table, column).Error
+				}
+				return tx.Exec("ALTER TABLE ? ALTER COLUMN ? SET DEFAULT ?", table, column, clause.Expr{SQL: to.defaultValue}).Error
+			}
+			return nil
+
+		case DriverMySQL:
+			return tx.Exec("ALTER TABLE ? MODIFY COLUMN ? ?", table, column, clause.Expr{SQL: to.definition()}).Error
+
+		case DriverSQLServer:
+			if diff.defaultValue {
+				note = "valeur par défaut à modifier à la main (contrainte DEFAULT nommée)"
+			}
+			if diff.dataType || diff.nullable {
+				definition := to.dataType + " NULL"
+				if to.notNull {
+					definition = to.dataType + " NOT NULL"
+				}
+				return tx.Exec("ALTER TABLE ? ALTER COLUMN ? ?", table, column, clause.Expr{SQL: definition}).Error
+			}
+			return nil
+
+		default:
+			note = "SQLite ne modifie pas les colonnes, à migrer à la main"
+			return nil
+		}
+	})
+	return statements, note, err
+}
+
+// compareIndexes crée les index du modèle absents ou différents dans la base
+// et supprime ceux que le modèle ne déclare plus
+func (d *schemaDiff) compareIndexes(model any, s *schema.Schema) error {
+	liveIndexes, err := d.db.Migrator().GetIndexes(model)
+	if err != nil {
+		return err
+	}
+	live := make(map[string]gorm.Index, len(liveIndexes))
+	for _, index := range liveIndexes {
+		live[index.Name()] = index
+	}
+
+	modelIndexes := s.ParseIndexes()
+	names := make([]string, 0, len(modelIndexes))
+	for name := range modelIndexes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		index := modelIndexes[name]
+		existing, exists := live[name]
+		if exists && sameIndex(index, existing) {
+			continue
+		}
+
+		up, err := d.capture(func(tx *gorm.DB) error {
+			if exists {
+				if err := tx.Migrator().DropIndex(model, name); err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().CreateIndex(model, name)
+		})
+		if err != nil {
+			return fmt.Errorf("index %s: %w", name, err)
+		}
+		down, err := d.capture(func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropIndex(model, name); err != nil {
+				return err
+			}
+			if exists {
+				return d.createLiveIndex(tx, s.Table, existing)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("index %s: %w", name, err)
+		}
+
+		comment := fmt.Sprintf("Table %s : nouvel index %s", s.Table, name)
+		if exists {
+			comment = fmt.Sprintf("Table %s : index %s modifié", s.Table, name)
+		}
+		d.indexes = append(d.indexes, schemaChange{comment: comment, up: up, down: down})
+	}
+
+	// Les index créés pour la clé primaire, les contraintes d'unicité et les
+	// clés étrangères ne sont pas déclarés comme index dans le modèle
+	constraints := make(map[string]bool)
+	for name := range s.ParseUniqueConstraints() {
+		constraints[name] = true
+	}
+	for _, rel := range s.Relationships.Relations {
+		if constraint := rel.ParseConstraint(); constraint != nil {
+			constraints[constraint.Name] = true
+		}
+	}
+
+	for _, index := range liveIndexes {
+		name := index.Name()
+		if _, declared := modelIndexes[name]; declared || constraints[name] || strings.HasPrefix(name, "sqlite_autoindex_") {
+			continue
+		}
+		if primaryKey, _ := index.PrimaryKey(); primaryKey {
+			continue
+		}
+		if unique, _ := index.Unique(); unique && len(index.Columns()) == 1 {
+			if field := s.LookUpField(index.Columns()[0]); field != nil && field.Unique {
+				continue
+			}
+		}
+
+		up, err := d.capture(func(tx *gorm.DB) error {
+			return tx.Migrator().DropIndex(model, name)
+		})
+		if err != nil {
+			return fmt.Errorf("index %s: %w", name, err)
+		}
+		down, err := d.capture(func(tx *gorm.DB) error {
+			return d.createLiveIndex(tx, s.Table, index)
+		})
+		if err != nil {
+			return fmt.Errorf("index %s: %w", name, err)
+		}
+
+		d.indexes = append(d.indexes, schemaChange{
+			comment: fmt.Sprintf("Table %s : index %s absent du modèle", s.Table, name),
+			up:      up,
+			down:    down,
+		})
+	}
+	return nil
+}
+
+// sameIndex indique si un index de la base correspond à celui du modèle
+func sameIndex(index schema.Index, live gorm.Index) bool {
+	columns := make([]string, 0, len(index.Fields))
+	for _, option := range index.Fields {
+		if option.Expression != "" || option.Field == nil {
+			// Les index sur une expression ne sont pas comparés
+			return true
+		}
+		columns = append(columns, strings.ToLower(option.DBName))
+	}
+
+	liveColumns := make([]string, 0, len(live.Columns()))
+	for _, column := range live.Columns() {
+		liveColumns = append(liveColumns, strings.ToLower(column))
+	}
+	if !slices.Equal(columns, liveColumns) {
+		return false
+	}
+
+	if unique, ok := live.Unique(); ok && unique != strings.EqualFold(index.Class, "UNIQUE") {
+		return false
+	}
+	return true
+}
+
+// createLiveIndex recrée un index de la base à partir de ses colonnes
+func (d *schemaDiff) createLiveIndex(tx *gorm.DB, table string, index gorm.Index) error {
+	columns := make([]any, 0, len(index.Columns()))
+	for _, column := range index.Columns() {
+		columns = append(columns, clause.Column{Name: column})
+	}
+
+	sql := "CREATE INDEX ? ON ??"
+	if unique, _ := index.Unique(); unique {
+		sql = "CREATE UNIQUE INDEX ? ON ??"
+	}
+	return tx.Exec(sql, clause.Column{Name: index.Name()}, clause.Table{Name: table}, columns).Error
+}
+
+// compareConstraints crée les clés étrangères du modèle absentes de la base.
+// SQLite ne sait pas ajouter une contrainte à une table existante.
+func (d *schemaDiff) compareConstraints(model any, s *schema.Schema) error {
+	if d.db.DisableForeignKeyConstraintWhenMigrating || d.db.IgnoreRelationshipsWhenMigrating || d.db.Dialector.Name() == DriverSQLite {
+		return nil
+	}
+
+	names := make([]string, 0, len(s.Relationships.Relations))
+	for name := range s.Relationships.Relations {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		rel := s.Relationships.Relations[name]
+		if rel.Field.IgnoreMigration {
+			continue
+		}
+		constraint := rel.ParseConstraint()
+		if constraint == nil || constraint.Schema != s || d.db.Migrator().HasConstraint(model, constraint.Name) {
+			continue
+		}
+
+		up, err := d.capture(func(tx *gorm.DB) error {
+			return tx.Migrator().CreateConstraint(model, constraint.Name)
+		})
+		if err != nil {
+			return fmt.Errorf("contrainte %s: %w", constraint.Name, err)
+		}
+		down, err := d.capture(func(tx *gorm.DB) error {
+			return tx.Migrator().DropConstraint(model, constraint.Name)
+		})
+		if err != nil {
+			return fmt.Errorf("contrainte %s: %w", constraint.Name, err)
+		}
+
+		d.constraints = append(d.constraints, schemaChange{
+			comment: fmt.Sprintf("Table %s : nouvelle clé étrangère %s vers %s", s.Table, constraint.Name, constraint.ReferenceSchema.Table),
+			up:      up,
+			down:    down,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package gormlib
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+type autogenAccount struct {
+	ID    uint
+	Email string `gorm:"size:255;uniqueIndex"`
+}
+
+type autogenInvoice struct {
+	ID        uint
+	AccountID uint
+	Account   autogenAccount
+}
+
+func TestGeneratedSQLIsUnqualified(t *testing.T) {
+	// Une connexion avec Config.Schema, comme l'ouvre NewConnection. Aucune
+	// requête n'est envoyée : le SQL est seulement capturé.
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost dbname=app"}), &gorm.Config{
+		NamingStrategy:       schema.NamingStrategy{TablePrefix: "app."},
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unqualified, err := unqualifiedDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff := &schemaDiff{ctx: context.Background(), db: unqualified}
+	if err := diff.createTables([]any{&autogenInvoice{}, &autogenAccount{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var statements []string
+	for _, change := range diff.created {
+		statements = append(statements, change.up...)
+		statements = append(statements, change.down...)
+	}
+	if len(statements) == 0 {
+		t.Fatal("aucune instruction générée")
+	}
+	for _, statement := range statements {
+		if strings.Contains(statement, "app") {
+			t.Errorf("instruction qualifiée par le schéma: %s", statement)
+		}
+	}
+	if !strings.HasPrefix(diff.created[0].up[0], `CREATE TABLE "autogen_accounts"`) {
+		t.Errorf("première instruction = %s, attendu la création de autogen_accounts", diff.created[0].up[0])
+	}
+}
@@ -0,0 +1,237 @@
+package gormlib
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationDialect regroupe les opérations du Migrator propres à chaque base
+// de données : verrou de migration et timeouts de session
+type migrationDialect interface {
+	// currentSchema retourne le schéma (ou la base) courant de la connexion
+	currentSchema(ctx context.Context, conn *sql.Conn) (string, error)
+
+	// sessionLocks indique si la base dispose de verrous de session. Sans eux,
+	// aucune connexion n'est réservée au verrou de migration.
+	sessionLocks() bool
+
+	// tryLock tente de prendre le verrou de session key sans attendre
+	tryLock(ctx context.Context, conn *sql.Conn, key int64) (bool, error)
+
+	// unlock libère le verrou de session key
+	unlock(ctx context.Context, conn *sql.Conn, key int64) error
+
+	// lockHolder retourne la session qui détient le verrou key, ou nil
+	lockHolder(ctx context.Context, db *gorm.DB, key int64) (*LockHolder, error)
+
+	// terminate termine la session pid
+	terminate(ctx context.Context, db *gorm.DB, pid int) error
+
+	// sessionTimeouts indique si StatementTimeout et LockTimeout sont supportés
+	sessionTimeouts() bool
+}
+
+// dialect retourne les opérations propres à la base de données du Migrator
+func (m *Migrator) dialect() migrationDialect {
+	switch m.db.Dialector.Name() {
+	case DriverPostgres:
+		return postgresDialect{}
+	case DriverMySQL:
+		return mysqlDialect{}
+	case DriverSQLServer:
+		return sqlserverDialect{}
+	default:
+		return genericDialect{}
+	}
+}
+
+// lockName retourne le nom du verrou applicatif correspondant à key, pour les
+// bases qui nomment leurs verrous
+func lockName(key int64) string {
+	return fmt.Sprintf("gormlib_%016x", uint64(key))
+}
+
+// errTerminateUnsupported est renvoyée par les bases qui ne permettent pas de
+// terminer une autre session
+var errTerminateUnsupported = errors.New("opération non supportée par cette base de données")
+
+// postgresDialect utilise les verrous consultatifs de PostgreSQL
+type postgresDialect struct{}
+
+func (postgresDialect) currentSchema(ctx context.Context, conn *sql.Conn) (string, error) {
+	var schema string
+	err := conn.QueryRowContext(ctx, "SELECT current_schema()").Scan(&schema)
+	return schema, err
+}
+
+func (postgresDialect) tryLock(ctx context.Context, conn *sql.Conn, key int64) (bool, error) {
+	var acquired bool
+	err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired)
+	return acquired, err
+}
+
+func (postgresDialect) unlock(ctx context.Context, conn *sql.Conn, key int64) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", key)
+	return err
+}
+
+func (postgresDialect) lockHolder(ctx context.Context, db *gorm.DB, key int64) (*LockHolder, error) {
+	var holder LockHolder
+	// backend_start date la connexion, souvent bien plus ancienne que le
+	// verrou avec un pool : la connexion du verrou reste inactive depuis sa
+	// prise, state_change en donne donc l'heure
+	row := db.WithContext(ctx).Raw(`SELECT l.pid, COALESCE(a.xact_start, a.state_change, a.backend_start)
+		FROM pg_locks l
+		JOIN pg_stat_activity a ON a.pid = l.pid
+		WHERE l.locktype = 'advisory' AND l.granted
+		  AND l.classid::bigint = ? AND l.objid::bigint = ? AND l.objsubid = 1`,
+		uint32(uint64(key)>>32), uint32(key)).Row()
+	if err := row.Scan(&holder.PID, &holder.Since); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &holder, nil
+}
+
+func (postgresDialect) terminate(ctx context.Context, db *gorm.DB, pid int) error {
+	var terminated bool
+	if err := db.WithContext(ctx).Raw("SELECT pg_terminate_backend(?)", pid).Row().Scan(&terminated); err != nil {
+		return err
+	}
+	if !terminated {
+		return fmt.Errorf("impossible de terminer la session %d", pid)
+	}
+	return nil
+}
+
+func (postgresDialect) sessionLocks() bool    { return true }
+func (postgresDialect) sessionTimeouts() bool { return true }
+
+// mysqlDialect utilise les verrous nommés de MySQL (GET_LOCK)
+type mysqlDialect struct{}
+
+func (mysqlDialect) currentSchema(ctx context.Context, conn *sql.Conn) (string, error) {
+	var schema sql.NullString
+	err := conn.QueryRowContext(ctx, "SELECT DATABASE()").Scan(&schema)
+	return schema.String, err
+}
+
+func (mysqlDialect) tryLock(ctx context.Context, conn *sql.Conn, key int64) (bool, error) {
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", lockName(key)).Scan(&acquired); err != nil {
+		return false, err
+	}
+	return acquired.Int64 == 1, nil
+}
+
+func (mysqlDialect) unlock(ctx context.Context, conn *sql.Conn, key int64) error {
+	var released sql.NullInt64
+	return conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", lockName(key)).Scan(&released)
+}
+
+func (mysqlDialect) lockHolder(ctx context.Context, db *gorm.DB, key int64) (*LockHolder, error) {
+	var holder LockHolder
+	var seconds int64
+	row := db.WithContext(ctx).Raw(`SELECT p.ID, p.TIME
+		FROM information_schema.PROCESSLIST p
+		WHERE p.ID = IS_USED_LOCK(?)`, lockName(key)).Row()
+	if err := row.Scan(&holder.PID, &seconds); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	// MySQL n'expose que la durée de la commande en cours
+	holder.Since = time.Now().Add(-time.Duration(seconds) * time.Second)
+	return &holder, nil
+}
+
+func (mysqlDialect) terminate(ctx context.Context, db *gorm.DB, pid int) error {
+	return db.WithContext(ctx).Exec(fmt.Sprintf("KILL %d", pid)).Error
+}
+
+func (mysqlDialect) sessionLocks() bool    { return true }
+func (mysqlDialect) sessionTimeouts() bool { return false }
+
+// sqlserverDialect utilise les verrous applicatifs de SQL Server (sp_getapplock)
+type sqlserverDialect struct{}
+
+func (sqlserverDialect) currentSchema(ctx context.Context, conn *sql.Conn) (string, error) {
+	var schema string
+	err := conn.QueryRowContext(ctx, "SELECT SCHEMA_NAME()").Scan(&schema)
+	return schema, err
+}
+
+func (sqlserverDialect) tryLock(ctx context.Context, conn *sql.Conn, key int64) (bool, error) {
+	var result int
+	err := conn.QueryRowContext(ctx, `DECLARE @result int;
+		EXEC @result = sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = 0;
+		SELECT @result`, lockName(key)).Scan(&result)
+	return result >= 0, err
+}
+
+func (sqlserverDialect) unlock(ctx context.Context, conn *sql.Conn, key int64) error {
+	_, err := conn.ExecContext(ctx, "EXEC sp_releaseapplock @Resource = @p1, @LockOwner = 'Session'", lockName(key))
+	return err
+}
+
+func (sqlserverDialect) lockHolder(ctx context.Context, db *gorm.DB, key int64) (*LockHolder, error) {
+	var holder LockHolder
+	// Comme pour PostgreSQL, la fin de la dernière requête de la session
+	// (sp_getapplock) date la prise du verrou mieux que login_time
+	row := db.WithContext(ctx).Raw(`SELECT TOP 1 l.request_session_id, COALESCE(s.last_request_end_time, s.login_time)
+		FROM sys.dm_tran_locks l
+		JOIN sys.dm_exec_sessions s ON s.session_id = l.request_session_id
+		WHERE l.resource_type = 'APPLICATION' AND l.request_status = 'GRANT'
+		  AND CHARINDEX(?, l.resource_description) > 0`,
+		":["+lockName(key)+"]").Row()
+	if err := row.Scan(&holder.PID, &holder.Since); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &holder, nil
+}
+
+func (sqlserverDialect) terminate(ctx context.Context, db *gorm.DB, pid int) error {
+	return db.WithContext(ctx).Exec(fmt.Sprintf("KILL %d", pid)).Error
+}
+
+func (sqlserverDialect) sessionLocks() bool    { return true }
+func (sqlserverDialect) sessionTimeouts() bool { return false }
+
+// genericDialect est utilisé pour les bases sans verrou de session, comme
+// SQLite : aucun verrou n'est pris, SQLite sérialisant de lui-même les
+// écritures concurrentes.
+type genericDialect struct{}
+
+func (genericDialect) currentSchema(context.Context, *sql.Conn) (string, error) {
+	return "main", nil
+}
+
+func (genericDialect) tryLock(context.Context, *sql.Conn, int64) (bool, error) {
+	return true, nil
+}
+
+func (genericDialect) unlock(context.Context, *sql.Conn, int64) error {
+	return nil
+}
+
+func (genericDialect) lockHolder(context.Context, *gorm.DB, int64) (*LockHolder, error) {
+	return nil, nil
+}
+
+func (genericDialect) terminate(context.Context, *gorm.DB, int) error {
+	return errTerminateUnsupported
+}
+
+func (genericDialect) sessionLocks() bool    { return false }
+func (genericDialect) sessionTimeouts() bool { return false }
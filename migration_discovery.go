@@ -1,9 +1,13 @@
 package gormlib
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
-	"path/filepath"
+	"path"
 	"sort"
 	"strings"
 	"time"
@@ -12,6 +16,7 @@ import (
 // MigrationDiscovery gère la découverte automatique des migrations
 type MigrationDiscovery struct {
 	MigrationsDir string
+	fsys          fs.FS
 	registry      *MigrationRegistry
 }
 
@@ -23,18 +28,40 @@ func NewMigrationDiscovery(migrationsDir string) *MigrationDiscovery {
 	}
 }
 
+// NewMigrationDiscoveryFS crée un découvreur de migrations qui lit les
+// fichiers dans le dossier root du système de fichiers fsys, par exemple un
+// embed.FS. Aucun fichier n'est lu ni écrit sur le disque.
+func NewMigrationDiscoveryFS(fsys fs.FS, root string) *MigrationDiscovery {
+	if root == "" {
+		root = "."
+	}
+	return &MigrationDiscovery{
+		MigrationsDir: root,
+		fsys:          fsys,
+		registry:      globalRegistry,
+	}
+}
+
+// NewMigrationDiscoveryWithRegistry crée un découvreur de migrations qui
+// recherche les migrations dans le registre fourni
+func NewMigrationDiscoveryWithRegistry(migrationsDir string, registry *MigrationRegistry) *MigrationDiscovery {
+	return &MigrationDiscovery{
+		MigrationsDir: migrationsDir,
+		registry:      registry,
+	}
+}
+
 // DiscoverMigrations découvre et charge automatiquement toutes les migrations
-// dans le dossier spécifié, triées par ordre chronologique
+// dans le dossier spécifié, triées par ordre chronologique. Si le dossier
+// n'existe pas, l'erreur retournée satisfait errors.Is(err, fs.ErrNotExist) :
+// elle se distingue ainsi d'un dossier sans migration.
 func (d *MigrationDiscovery) DiscoverMigrations() ([]Migration, error) {
-	// Créer le dossier migrations s'il n'existe pas
-	if err := os.MkdirAll(d.MigrationsDir, 0755); err != nil {
-		return nil, fmt.Errorf("erreur lors de la création du dossier migrations: %v", err)
-	}
+	fsys, root := d.filesystem()
 
-	// Lire tous les fichiers .go dans le dossier migrations
-	files, err := os.ReadDir(d.MigrationsDir)
+	// Lire tous les fichiers du dossier migrations
+	files, err := fs.ReadDir(fsys, root)
 	if err != nil {
-		return nil, fmt.Errorf("erreur lors de la lecture du dossier migrations: %v", err)
+		return nil, fmt.Errorf("erreur lors de la lecture du dossier migrations: %w", err)
 	}
 
 	type migrationInfo struct {
@@ -43,8 +70,14 @@ func (d *MigrationDiscovery) DiscoverMigrations() ([]Migration, error) {
 	}
 
 	var migrationsInfo []migrationInfo
+	sqlNames := make(map[string]bool)
 	for _, file := range files {
-		if !file.IsDir() && strings.HasSuffix(file.Name(), ".go") {
+		if file.IsDir() {
+			continue
+		}
+
+		switch {
+		case strings.HasSuffix(file.Name(), MigrationFileSuffix):
 			// Extraire le timestamp et le nom de la migration
 			timestamp, name, err := d.parseMigrationFileName(file.Name())
 			if err != nil {
@@ -54,17 +87,47 @@ func (d *MigrationDiscovery) DiscoverMigrations() ([]Migration, error) {
 			// Vérifier si la migration est déjà enregistrée
 			migration := d.registry.GetMigrationByName(name)
 			if migration != nil {
+				checksum, err := fileChecksum(fsys, path.Join(root, file.Name()))
+				if err != nil {
+					return nil, fmt.Errorf("erreur lors de la lecture de la migration %s: %v", file.Name(), err)
+				}
 				migrationsInfo = append(migrationsInfo, migrationInfo{
-					migration: migration,
+					migration: &fileMigration{Migration: migration, checksum: checksum},
 					timestamp: timestamp,
 				})
 			}
+
+		case strings.HasSuffix(file.Name(), SQLUpSuffix):
+			timestamp, name, err := d.parseMigrationFileName(file.Name())
+			if err != nil {
+				continue
+			}
+
+			migration, err := d.loadSQLMigration(name)
+			if err != nil {
+				return nil, err
+			}
+			sqlNames[name] = true
+			migrationsInfo = append(migrationsInfo, migrationInfo{
+				migration: migration,
+				timestamp: timestamp,
+			})
+		}
+	}
+
+	// Une migration ne peut pas être définie à la fois en Go et en SQL
+	for _, info := range migrationsInfo {
+		if _, isSQL := info.migration.(*SQLMigration); !isSQL && sqlNames[info.migration.Name()] {
+			return nil, fmt.Errorf("la migration %s est définie à la fois en Go et en SQL", info.migration.Name())
 		}
 	}
 
 	// Trier les migrations par timestamp
 	sort.Slice(migrationsInfo, func(i, j int) bool {
-		return migrationsInfo[i].timestamp.Before(migrationsInfo[j].timestamp)
+		if !migrationsInfo[i].timestamp.Equal(migrationsInfo[j].timestamp) {
+			return migrationsInfo[i].timestamp.Before(migrationsInfo[j].timestamp)
+		}
+		return migrationsInfo[i].migration.Name() < migrationsInfo[j].migration.Name()
 	})
 
 	// Convertir en slice de Migration
@@ -76,10 +139,26 @@ func (d *MigrationDiscovery) DiscoverMigrations() ([]Migration, error) {
 	return migrations, nil
 }
 
+// filesystem retourne le système de fichiers et le dossier où chercher les
+// migrations
+func (d *MigrationDiscovery) filesystem() (fs.FS, string) {
+	if d.fsys != nil {
+		return d.fsys, d.MigrationsDir
+	}
+	return os.DirFS(d.MigrationsDir), "."
+}
+
 // parseMigrationFileName extrait le timestamp et le nom de la migration du nom de fichier
 func (d *MigrationDiscovery) parseMigrationFileName(filename string) (time.Time, string, error) {
-	// Format attendu: YYYYMMDDHHMMSS_name.go
-	base := strings.TrimSuffix(filename, ".go")
+	// Format attendu: YYYYMMDDHHMMSS_name.go, YYYYMMDDHHMMSS_name.up.sql
+	// ou YYYYMMDDHHMMSS_name.down.sql
+	base := filename
+	for _, suffix := range []string{SQLUpSuffix, SQLDownSuffix, MigrationFileSuffix} {
+		if strings.HasSuffix(base, suffix) {
+			base = strings.TrimSuffix(base, suffix)
+			break
+		}
+	}
 	parts := strings.SplitN(base, "_", 2)
 	if len(parts) != 2 {
 		return time.Time{}, "", fmt.Errorf("format de nom de fichier invalide: %s", filename)
@@ -93,6 +172,39 @@ func (d *MigrationDiscovery) parseMigrationFileName(filename string) (time.Time,
 	return timestamp, base, nil
 }
 
+// loadSQLMigration charge une migration SQL à partir de ses fichiers up et down
+func (d *MigrationDiscovery) loadSQLMigration(name string) (*SQLMigration, error) {
+	fsys, root := d.filesystem()
+
+	up, err := fs.ReadFile(fsys, path.Join(root, name+SQLUpSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la lecture de la migration %s: %v", name+SQLUpSuffix, err)
+	}
+
+	// Le fichier down est facultatif
+	down, err := fs.ReadFile(fsys, path.Join(root, name+SQLDownSuffix))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("erreur lors de la lecture de la migration %s: %v", name+SQLDownSuffix, err)
+	}
+
+	return &SQLMigration{
+		name:    name,
+		up:      string(up),
+		down:    string(down),
+		hasDown: err == nil,
+	}, nil
+}
+
+// fileChecksum retourne l'empreinte SHA-256 du contenu d'un fichier
+func fileChecksum(fsys fs.FS, name string) (string, error) {
+	content, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
 // ValidateMigrationFile vérifie si un fichier de migration est valide
 func (d *MigrationDiscovery) ValidateMigrationFile(filename string) error {
 	// Vérifier le format du nom de fichier
@@ -101,8 +213,8 @@ func (d *MigrationDiscovery) ValidateMigrationFile(filename string) error {
 	}
 
 	// Vérifier que le fichier existe
-	filePath := filepath.Join(d.MigrationsDir, filename)
-	if _, err := os.Stat(filePath); err != nil {
+	fsys, root := d.filesystem()
+	if _, err := fs.Stat(fsys, path.Join(root, filename)); err != nil {
 		return fmt.Errorf("fichier de migration non trouvé: %v", err)
 	}
 
@@ -0,0 +1,34 @@
+package gormlib
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDiscoverMigrationsMissingDir(t *testing.T) {
+	discovery := NewMigrationDiscoveryFS(fstest.MapFS{}, "migrations")
+
+	migrations, err := discovery.DiscoverMigrations()
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("DiscoverMigrations() erreur = %v, attendu fs.ErrNotExist", err)
+	}
+	if migrations != nil {
+		t.Errorf("DiscoverMigrations() = %v, attendu nil", migrations)
+	}
+}
+
+func TestDiscoverMigrationsEmptyDir(t *testing.T) {
+	discovery := NewMigrationDiscoveryFS(fstest.MapFS{
+		"migrations/README.md": &fstest.MapFile{Data: []byte("notes")},
+	}, "migrations")
+
+	migrations, err := discovery.DiscoverMigrations()
+	if err != nil {
+		t.Fatalf("DiscoverMigrations() erreur = %v", err)
+	}
+	if len(migrations) != 0 {
+		t.Errorf("DiscoverMigrations() = %d migration(s), attendu 0", len(migrations))
+	}
+}
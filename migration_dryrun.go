@@ -0,0 +1,195 @@
+package gormlib
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// MigrationDirection indique le sens d'exécution d'une migration
+type MigrationDirection string
+
+const (
+	// DirectionUp correspond à l'application d'une migration
+	DirectionUp MigrationDirection = "up"
+
+	// DirectionDown correspond au rollback d'une migration
+	DirectionDown MigrationDirection = "down"
+)
+
+// MigrationPlan contient les instructions SQL qu'exécuterait une migration
+type MigrationPlan struct {
+	Name       string
+	Direction  MigrationDirection
+	Statements []string
+}
+
+// introspectionQuery reconnaît les requêtes de lecture du catalogue émises
+// par le Migrator de GORM et ses pilotes (HasTable, HasColumn, HasIndex,
+// ColumnTypes, CurrentDatabase, ...)
+var introspectionQuery = regexp.MustCompile(`(?is)` +
+	`^SELECT\b.*\b(information_schema|pg_catalog|pg_indexes|pg_attribute|sqlite_master|pragma_index_\w+|sys\.\w+)\b` +
+	`|^SELECT\s+(CURRENT_DATABASE|CURRENT_SCHEMA|DATABASE|DB_NAME|SCHEMA_NAME|VERSION|sqlite_version)\(\)` +
+	`|^SELECT \* FROM \S+ LIMIT \S+$` +
+	`|^PRAGMA database_list$`)
+
+// statementRecorder est un logger GORM qui enregistre les instructions SQL
+// générées au lieu de les afficher
+type statementRecorder struct {
+	mu         sync.Mutex
+	statements []string
+}
+
+func (r *statementRecorder) LogMode(logger.LogLevel) logger.Interface      { return r }
+func (r *statementRecorder) Info(context.Context, string, ...interface{})  {}
+func (r *statementRecorder) Warn(context.Context, string, ...interface{})  {}
+func (r *statementRecorder) Error(context.Context, string, ...interface{}) {}
+
+func (r *statementRecorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	sql, _ := fc()
+	sql = strings.TrimSpace(sql)
+
+	// Les lectures du catalogue par le Migrator de GORM ne font pas partie de
+	// la migration. Les autres SELECT (setval, create_hypertable, ...) en font
+	// partie.
+	if sql == "" || introspectionQuery.MatchString(sql) {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statements = append(r.statements, sql)
+}
+
+// captureStatements exécute fn sur une session GORM en mode DryRun et retourne
+// les instructions SQL générées, sans rien exécuter sur la base
+func (m *Migrator) captureStatements(ctx context.Context, fn func(db *gorm.DB) error) ([]string, error) {
+	return captureStatements(ctx, m.db, fn)
+}
+
+// captureStatements exécute fn sur une session DryRun de db et retourne les
+// instructions SQL générées.
+//
+// Row et Rows ne retournent pas de résultat en mode DryRun : fn panique alors
+// en lisant la ligne. Cette panique, comme toute autre panique de fn, est
+// retournée sous la forme d'une erreur qui satisfait
+// errors.Is(err, gorm.ErrDryRunModeUnsupported) ; l'appelant y ajoute le nom
+// de la migration.
+func captureStatements(ctx context.Context, db *gorm.DB, fn func(db *gorm.DB) error) (statements []string, err error) {
+	recorder := &statementRecorder{}
+	db = db.Session(&gorm.Session{DryRun: true, Logger: recorder, Context: ctx})
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", gorm.ErrDryRunModeUnsupported, r)
+		}
+	}()
+
+	err = fn(db)
+	return recorder.statements, err
+}
+
+// PlanMigrations retourne les instructions SQL qu'exécuteraient les
+// migrations en attente, dans l'ordre, sans modifier la base de données
+func (m *Migrator) PlanMigrations(migrations ...Migration) ([]MigrationPlan, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.Timeout)
+	defer cancel()
+
+	return m.planMigrations(ctx, migrations)
+}
+
+// planMigrations calcule les plans des migrations en attente
+func (m *Migrator) planMigrations(ctx context.Context, migrations []Migration) ([]MigrationPlan, error) {
+	pending, err := m.pendingMigrations(ctx, migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	plans := make([]MigrationPlan, 0, len(pending))
+	for _, migration := range pending {
+		statements, err := m.captureStatements(ctx, migration.Up)
+		if err != nil {
+			return nil, NewMigrationError("plan migration "+migration.Name(), err)
+		}
+		plans = append(plans, MigrationPlan{
+			Name:       migration.Name(),
+			Direction:  DirectionUp,
+			Statements: statements,
+		})
+	}
+
+	return plans, nil
+}
+
+// PlanRollback retourne les instructions SQL qu'exécuterait le rollback d'une
+// migration appliquée, sans modifier la base de données
+func (m *Migrator) PlanRollback(migration Migration) (*MigrationPlan, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.Timeout)
+	defer cancel()
+
+	return m.planRollback(ctx, migration)
+}
+
+// planRollback calcule le plan du rollback d'une migration appliquée
+func (m *Migrator) planRollback(ctx context.Context, migration Migration) (*MigrationPlan, error) {
+	applied, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	found := false
+	for _, record := range applied {
+		if record.Name == migration.Name() {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, migrationNotFound(migration)
+	}
+
+	statements, err := m.captureStatements(ctx, migration.Down)
+	if err != nil {
+		return nil, NewMigrationError("plan rollback "+migration.Name(), err)
+	}
+
+	return &MigrationPlan{
+		Name:       migration.Name(),
+		Direction:  DirectionDown,
+		Statements: statements,
+	}, nil
+}
+
+// printPlans écrit les instructions SQL des plans sur la sortie du mode dry-run
+func (m *Migrator) printPlans(plans ...MigrationPlan) error {
+	var w io.Writer = os.Stdout
+	if m.config.DryRunOutput != nil {
+		w = m.config.DryRunOutput
+	}
+
+	for _, plan := range plans {
+		if _, err := fmt.Fprintf(w, "-- %s (%s)\n", plan.Name, plan.Direction); err != nil {
+			return err
+		}
+		for _, statement := range plan.Statements {
+			if !strings.HasSuffix(statement, ";") {
+				statement += ";"
+			}
+			if _, err := fmt.Fprintln(w, statement); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package gormlib
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestIntrospectionQuery(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{`SELECT count(*) FROM information_schema.tables WHERE table_schema = 'app' AND table_name = 'users'`, true},
+		{`SELECT count(*) FROM pg_indexes WHERE tablename = 'users' AND indexname = 'idx_users_email'`, true},
+		{`SELECT description FROM pg_catalog.pg_description WHERE objoid = 1`, true},
+		{`SELECT count(*) FROM sqlite_master WHERE type='table' AND name='users'`, true},
+		{`SELECT count(*) FROM sys.indexes WHERE name='idx' AND object_id=OBJECT_ID('users')`, true},
+		{`SELECT CURRENT_DATABASE()`, true},
+		{`SELECT DATABASE()`, true},
+		{`SELECT DB_NAME() AS [Current Database]`, true},
+		{`SELECT * FROM "users" LIMIT 1`, true},
+		{`PRAGMA database_list`, true},
+		{`SELECT setval('users_id_seq', 42)`, false},
+		{`SELECT create_hypertable('metrics', 'time')`, false},
+		{`SELECT pg_advisory_xact_lock(42)`, false},
+		{`CREATE TABLE "users" ("id" bigserial)`, false},
+		{`INSERT INTO settings (name) SELECT name FROM defaults`, false},
+	}
+
+	for _, tt := range tests {
+		if got := introspectionQuery.MatchString(tt.sql); got != tt.want {
+			t.Errorf("introspectionQuery.MatchString(%q) = %v, attendu %v", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestCaptureStatements(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+
+	statements, err := captureStatements(context.Background(), db, func(tx *gorm.DB) error {
+		tx.Exec("CREATE TABLE users (id integer)")
+		return tx.Exec("SELECT setval('users_id_seq', 42)").Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"CREATE TABLE users (id integer)", "SELECT setval('users_id_seq', 42)"}
+	if !slices.Equal(statements, want) {
+		t.Errorf("captureStatements() = %q, attendu %q", statements, want)
+	}
+
+	// Row ne retourne rien en mode DryRun
+	_, err = captureStatements(context.Background(), db, func(tx *gorm.DB) error {
+		var count int
+		return tx.Raw("SELECT count(*) FROM users").Row().Scan(&count)
+	})
+	if !errors.Is(err, gorm.ErrDryRunModeUnsupported) {
+		t.Errorf("captureStatements() erreur = %v, attendu gorm.ErrDryRunModeUnsupported", err)
+	}
+}
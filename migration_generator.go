@@ -2,10 +2,16 @@ package gormlib
 
 import (
 	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 const (
@@ -43,12 +49,42 @@ func (m *%s) Name() string {
 	return "%s"
 }
 `
+
+	registryTemplate = `// Code generated by gormlib. DO NOT EDIT.
+
+package %s
+
+import (
+	"github.com/urmaps/z-gormlib"
+)
+
+func init() {
+	for _, migration := range []gormlib.Migration{
+%s	} {
+		if err := gormlib.RegisterGlobal(migration); err != nil {
+			panic(err)
+		}
+	}
+}
+`
+
+	sqlUpTemplate = `-- Migration %s
+-- Instructions exécutées par -migrate
+`
+
+	sqlDownTemplate = `-- Rollback de la migration %s
+-- Instructions exécutées par -rollback
+`
+
+	// RegistryFileName est le nom du fichier généré qui enregistre les migrations
+	RegistryFileName = "registry_gen.go"
 )
 
 // MigrationGenerator gère la génération des fichiers de migration
 type MigrationGenerator struct {
 	MigrationsDir string
 	config        *MigrationConfig
+	db            *gorm.DB // Base comparée aux modèles par GenerateFromModels
 }
 
 // NewMigrationGenerator crée un nouveau générateur de migrations
@@ -64,36 +100,154 @@ func NewMigrationGenerator(migrationsDir string, config *MigrationConfig) *Migra
 
 // GenerateMigration crée une nouvelle migration à partir d'un nom
 func (g *MigrationGenerator) GenerateMigration(name string) error {
+	migrationName, structName, filePath, err := g.prepareMigrationFile(name)
+	if err != nil {
+		return err
+	}
+
+	// Créer le fichier de migration
+	content := fmt.Sprintf(migrationTemplate,
+		structName, name, structName, structName, structName, structName, migrationName)
+
+	if err := os.WriteFile(filePath, []byte(content), DefaultFileMode); err != nil {
+		return NewMigrationError("create migration file", err)
+	}
+
+	// Mettre à jour l'index des migrations
+	return g.GenerateRegistry()
+}
+
+// prepareMigrationFile valide le nom d'une nouvelle migration Go et retourne
+// son nom horodaté, le nom de sa structure et le chemin de son fichier, qui
+// ne doit pas déjà exister
+func (g *MigrationGenerator) prepareMigrationFile(name string) (migrationName, structName, filePath string, err error) {
 	// Valider le nom de la migration
 	if err := g.validateMigrationName(name); err != nil {
-		return err
+		return "", "", "", err
 	}
 
 	// Créer le timestamp pour le nom du fichier
 	timestamp := time.Now().Format("20060102150405")
-	migrationName := fmt.Sprintf("%s_%s", timestamp, strings.ToLower(name))
-	
+	migrationName = fmt.Sprintf("%s_%s", timestamp, strings.ToLower(name))
+
 	// Créer le nom de la structure Go
-	structName := fmt.Sprintf("%s%s", MigrationStructPrefix, strings.Title(name))
+	structName = fmt.Sprintf("%s%s", MigrationStructPrefix, strings.Title(name))
 
 	// Créer le dossier migrations s'il n'existe pas
 	if g.config.AutoCreateDir {
 		if err := os.MkdirAll(g.MigrationsDir, DefaultDirMode); err != nil {
-			return NewMigrationError("create migrations directory", err)
+			return "", "", "", NewMigrationError("create migrations directory", err)
 		}
 	}
 
 	// Vérifier si le fichier existe déjà
-	filePath := filepath.Join(g.MigrationsDir, fmt.Sprintf("%s%s", migrationName, MigrationFileSuffix))
+	filePath = filepath.Join(g.MigrationsDir, fmt.Sprintf("%s%s", migrationName, MigrationFileSuffix))
 	if _, err := os.Stat(filePath); err == nil {
-		return ErrMigrationAlreadyExists
+		return "", "", "", ErrMigrationAlreadyExists
 	}
 
-	// Créer le fichier de migration
-	content := fmt.Sprintf(migrationTemplate, 
-		structName, name, structName, structName, structName, structName, migrationName)
-	
-	if err := os.WriteFile(filePath, []byte(content), DefaultFileMode); err != nil {
+	return migrationName, structName, filePath, nil
+}
+
+// GenerateRegistry (ré)génère le fichier registry_gen.go du dossier des
+// migrations. Son init() enregistre dans le registre global chaque type
+// défini dans un fichier de migration, afin que l'import du paquet suffise à
+// rendre les migrations disponibles.
+func (g *MigrationGenerator) GenerateRegistry() error {
+	files, err := os.ReadDir(g.MigrationsDir)
+	if err != nil {
+		return NewMigrationError("read migrations directory", err)
+	}
+
+	discovery := NewMigrationDiscovery(g.MigrationsDir)
+	fset := token.NewFileSet()
+	packageName := DefaultMigrationsDir
+
+	// os.ReadDir trie les fichiers par nom, donc par ordre chronologique
+	var entries strings.Builder
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, MigrationFileSuffix) ||
+			name == RegistryFileName || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		if _, _, err := discovery.parseMigrationFileName(name); err != nil {
+			continue
+		}
+
+		parsed, err := parser.ParseFile(fset, filepath.Join(g.MigrationsDir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return NewMigrationError("parse migration file", err)
+		}
+		packageName = parsed.Name.Name
+
+		for _, typeName := range migrationTypes(parsed) {
+			fmt.Fprintf(&entries, "\t\t&%s{},\n", typeName)
+		}
+	}
+
+	content, err := format.Source([]byte(fmt.Sprintf(registryTemplate, packageName, entries.String())))
+	if err != nil {
+		return NewMigrationError("format migrations registry", err)
+	}
+
+	filePath := filepath.Join(g.MigrationsDir, RegistryFileName)
+	if err := os.WriteFile(filePath, content, DefaultFileMode); err != nil {
+		return NewMigrationError("write migrations registry", err)
+	}
+
+	return nil
+}
+
+// migrationTypes retourne les types d'un fichier qui définissent une méthode Name
+func migrationTypes(file *ast.File) []string {
+	var types []string
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil || len(fn.Recv.List) != 1 || fn.Name.Name != "Name" {
+			continue
+		}
+
+		recv := fn.Recv.List[0].Type
+		if star, ok := recv.(*ast.StarExpr); ok {
+			recv = star.X
+		}
+		if ident, ok := recv.(*ast.Ident); ok {
+			types = append(types, ident.Name)
+		}
+	}
+	return types
+}
+
+// GenerateSQLMigration crée une nouvelle migration SQL (paire de fichiers
+// .up.sql / .down.sql) à partir d'un nom
+func (g *MigrationGenerator) GenerateSQLMigration(name string) error {
+	// Valider le nom de la migration
+	if err := g.validateMigrationName(name); err != nil {
+		return err
+	}
+
+	timestamp := time.Now().Format("20060102150405")
+	migrationName := fmt.Sprintf("%s_%s", timestamp, strings.ToLower(name))
+
+	if g.config.AutoCreateDir {
+		if err := os.MkdirAll(g.MigrationsDir, DefaultDirMode); err != nil {
+			return NewMigrationError("create migrations directory", err)
+		}
+	}
+
+	upPath := filepath.Join(g.MigrationsDir, migrationName+SQLUpSuffix)
+	downPath := filepath.Join(g.MigrationsDir, migrationName+SQLDownSuffix)
+	for _, path := range []string{upPath, downPath} {
+		if _, err := os.Stat(path); err == nil {
+			return ErrMigrationAlreadyExists
+		}
+	}
+
+	if err := os.WriteFile(upPath, []byte(fmt.Sprintf(sqlUpTemplate, name)), DefaultFileMode); err != nil {
+		return NewMigrationError("create migration file", err)
+	}
+	if err := os.WriteFile(downPath, []byte(fmt.Sprintf(sqlDownTemplate, name)), DefaultFileMode); err != nil {
 		return NewMigrationError("create migration file", err)
 	}
 
@@ -123,4 +277,4 @@ func isValidMigrationName(name string) bool {
 		}
 	}
 	return true
-} 
\ No newline at end of file
+}
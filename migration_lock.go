@@ -0,0 +1,248 @@
+package gormlib
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// lockPollInterval est l'intervalle entre deux tentatives d'acquisition du verrou
+const lockPollInterval = 500 * time.Millisecond
+
+// migrationLock représente un verrou de niveau session (verrou consultatif
+// PostgreSQL, GET_LOCK MySQL, sp_getapplock SQL Server) détenu sur une
+// connexion dédiée
+type migrationLock struct {
+	dialect migrationDialect
+	conn    *sql.Conn
+	pinned  bool // conn est la connexion du Migrator, à ne pas rendre au pool
+	key     int64
+}
+
+// LockHolder décrit la session qui détient le verrou de migration
+type LockHolder struct {
+	PID int
+	// Since est l'heure de la dernière activité de la session, c'est-à-dire
+	// de la prise du verrou tant que sa connexion dédiée reste inactive. Ce
+	// n'est pas l'heure d'ouverture de la session.
+	Since time.Time
+}
+
+func (h *LockHolder) String() string {
+	return fmt.Sprintf("verrou détenu par le pid %d depuis %s", h.PID, h.Since.Format(time.RFC3339))
+}
+
+// acquireLock prend le verrou de migration, en attendant au plus
+// LockWaitTimeout qu'il soit libéré par une autre instance
+func (m *Migrator) acquireLock(ctx context.Context) (*migrationLock, error) {
+	dialect := m.dialect()
+	if !dialect.sessionLocks() {
+		return &migrationLock{dialect: dialect}, nil
+	}
+
+	// Sans LockWaitTimeout, l'attente s'arrête au délai de ctx
+	start := time.Now()
+	if m.config.LockWaitTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.config.LockWaitTimeout)
+		defer cancel()
+	}
+
+	// Le verrou est lié à la session: il faut conserver la même connexion
+	// jusqu'à sa libération
+	conn, pinned, err := m.sessionConn(ctx)
+	if err != nil {
+		return nil, NewMigrationError("acquire migration lock", err)
+	}
+	lock := &migrationLock{dialect: dialect, conn: conn, pinned: pinned}
+
+	lock.key, err = m.lockKey(ctx, dialect, conn)
+	if err != nil {
+		lock.close()
+		return nil, NewMigrationError("acquire migration lock", err)
+	}
+
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+
+	for {
+		acquired, err := dialect.tryLock(ctx, conn, lock.key)
+		if err != nil {
+			lock.close()
+			if errors.Is(err, context.DeadlineExceeded) {
+				return nil, m.lockTimeoutError(lock.key, time.Since(start))
+			}
+			return nil, NewMigrationError("acquire migration lock", err)
+		}
+		if acquired {
+			return lock, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			lock.close()
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, m.lockTimeoutError(lock.key, time.Since(start))
+			}
+			return nil, NewMigrationError("acquire migration lock", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// release libère le verrou et rend la connexion au pool
+func (l *migrationLock) release() error {
+	if l.conn == nil {
+		return nil
+	}
+	defer l.close()
+
+	// Le verrou doit être libéré même si le contexte des migrations a expiré
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := l.dialect.unlock(ctx, l.conn, l.key); err != nil {
+		return NewMigrationError("release migration lock", err)
+	}
+	return nil
+}
+
+// close rend la connexion du verrou au pool, sauf si c'est celle du Migrator
+func (l *migrationLock) close() {
+	if !l.pinned {
+		l.conn.Close()
+	}
+}
+
+// sessionConn retourne une connexion dédiée du pool, ou la connexion à
+// laquelle le Migrator est lié (voir TenantMigrator). pinned indique que la
+// connexion appartient au Migrator et ne doit pas être fermée.
+func (m *Migrator) sessionConn(ctx context.Context) (conn *sql.Conn, pinned bool, err error) {
+	if conn, ok := m.db.ConnPool.(*sql.Conn); ok {
+		return conn, true, nil
+	}
+
+	sqlDB, err := m.db.DB()
+	if err != nil {
+		return nil, false, err
+	}
+	conn, err = sqlDB.Conn(ctx)
+	return conn, false, err
+}
+
+// withLock exécute fn en détenant le verrou de migration
+func (m *Migrator) withLock(ctx context.Context, fn func() error) (err error) {
+	lock, err := m.acquireLock(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if releaseErr := lock.release(); err == nil {
+			err = releaseErr
+		}
+	}()
+
+	return fn()
+}
+
+// lockKey calcule la clé du verrou à partir du schéma de la table des
+// migrations (le schéma courant si elle n'est pas qualifiée) et de son nom
+func (m *Migrator) lockKey(ctx context.Context, dialect migrationDialect, conn *sql.Conn) (int64, error) {
+	table := m.historyTable()
+	if tableSchema, _ := splitTable(table); tableSchema == "" {
+		schema, err := dialect.currentSchema(ctx, conn)
+		if err != nil {
+			return 0, err
+		}
+		table = schema + "." + table
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(table))
+	return int64(h.Sum64()), nil
+}
+
+// lockTimeoutError construit l'erreur renvoyée lorsque le verrou n'a pas pu
+// être obtenu après avoir attendu waited, avec le détenteur actuel si on peut
+// l'identifier
+func (m *Migrator) lockTimeoutError(key int64, waited time.Duration) error {
+	waited = waited.Round(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	holder, err := m.lockHolder(ctx, key)
+	if err != nil || holder == nil {
+		return newMigrationError(ErrMigrationLocked, "acquire migration lock",
+			fmt.Errorf("verrou non obtenu après %s", waited))
+	}
+	return newMigrationError(ErrMigrationLocked, "acquire migration lock",
+		fmt.Errorf("verrou non obtenu après %s: %s", waited, holder))
+}
+
+// lockHolder retourne la session qui détient le verrou, ou nil s'il est libre
+func (m *Migrator) lockHolder(ctx context.Context, key int64) (*LockHolder, error) {
+	return m.dialect().lockHolder(ctx, m.db, key)
+}
+
+// LockStatus retourne la session qui détient actuellement le verrou de
+// migration, ou nil s'il est libre
+func (m *Migrator) LockStatus() (*LockHolder, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.Timeout)
+	defer cancel()
+
+	key, err := m.currentLockKey(ctx)
+	if err != nil {
+		return nil, NewMigrationError("get migration lock status", err)
+	}
+
+	holder, err := m.lockHolder(ctx, key)
+	if err != nil {
+		return nil, NewMigrationError("get migration lock status", err)
+	}
+	return holder, nil
+}
+
+// ForceUnlock libère un verrou de migration bloqué en terminant la session
+// qui le détient. Retourne le pid de la session terminée, ou 0 si le verrou
+// était libre.
+func (m *Migrator) ForceUnlock() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.Timeout)
+	defer cancel()
+
+	key, err := m.currentLockKey(ctx)
+	if err != nil {
+		return 0, NewMigrationError("force unlock", err)
+	}
+
+	holder, err := m.lockHolder(ctx, key)
+	if err != nil {
+		return 0, NewMigrationError("force unlock", err)
+	}
+	if holder == nil {
+		return 0, nil
+	}
+
+	// Un verrou de session ne peut être libéré que par la session qui le
+	// détient: on termine donc cette session
+	if err := m.dialect().terminate(ctx, m.db, holder.PID); err != nil {
+		return 0, NewMigrationError("force unlock", err)
+	}
+	return holder.PID, nil
+}
+
+// currentLockKey calcule la clé du verrou sur une connexion du pool
+func (m *Migrator) currentLockKey(ctx context.Context) (int64, error) {
+	conn, pinned, err := m.sessionConn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !pinned {
+		defer conn.Close()
+	}
+
+	return m.lockKey(ctx, m.dialect(), conn)
+}
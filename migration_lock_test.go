@@ -0,0 +1,34 @@
+package gormlib
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestLockTimeoutErrorReportsTimeWaited(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+
+	// Attente illimitée : seul le délai de la migration l'a interrompue
+	m := NewMigrator(db, &MigrationConfig{LockWaitTimeout: 0})
+	err = m.lockTimeoutError(42, 5*time.Minute+200*time.Millisecond)
+
+	if !errors.Is(err, ErrMigrationLocked) {
+		t.Errorf("lockTimeoutError() = %v, attendu ErrMigrationLocked", err)
+	}
+	if !strings.Contains(err.Error(), "après 5m0s") {
+		t.Errorf("lockTimeoutError() = %q, attendu la durée d'attente 5m0s", err)
+	}
+}
@@ -2,6 +2,7 @@ package gormlib
 
 import (
 	"fmt"
+	"slices"
 	"sort"
 	"sync"
 )
@@ -9,6 +10,7 @@ import (
 // MigrationRegistry gère l'enregistrement et le suivi des migrations
 type MigrationRegistry struct {
 	migrations map[string]Migration
+	models     []any // Modèles comparés au schéma par -autogen
 	mu         sync.RWMutex
 }
 
@@ -65,11 +67,39 @@ func (r *MigrationRegistry) GetAllMigrations() []Migration {
 	return migrations
 }
 
+// RegisterModel enregistre des modèles GORM, comparés au schéma de la base
+// pour générer une migration (-autogen)
+func (r *MigrationRegistry) RegisterModel(models ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.models = append(r.models, models...)
+}
+
+// Models retourne les modèles enregistrés, dans l'ordre d'enregistrement
+func (r *MigrationRegistry) Models() []any {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return slices.Clone(r.models)
+}
+
+// GlobalRegistry retourne le registre global des migrations, alimenté par les
+// fichiers registry_gen.go générés
+func GlobalRegistry() *MigrationRegistry {
+	return globalRegistry
+}
+
 // RegisterGlobal enregistre une migration dans le registre global
 func RegisterGlobal(migration Migration) error {
 	return globalRegistry.Register(migration)
 }
 
+// RegisterGlobalModel enregistre des modèles GORM dans le registre global
+func RegisterGlobalModel(models ...any) {
+	globalRegistry.RegisterModel(models...)
+}
+
 // GetGlobalMigrationByName retourne une migration du registre global par son nom
 func GetGlobalMigrationByName(name string) Migration {
 	return globalRegistry.GetMigrationByName(name)
@@ -95,6 +125,7 @@ func (r *MigrationRegistry) Clear() {
 	defer r.mu.Unlock()
 
 	r.migrations = make(map[string]Migration)
+	r.models = nil
 }
 
 // Count retourne le nombre de migrations enregistrées
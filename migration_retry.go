@@ -0,0 +1,151 @@
+package gormlib
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	mssql "github.com/microsoft/go-mssqldb"
+)
+
+// retryableSQLStates sont les codes SQLSTATE pour lesquels une nouvelle
+// tentative peut réussir
+var retryableSQLStates = map[string]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"55P03": true, // lock_not_available (lock_timeout)
+}
+
+// retryableMySQLErrors sont les codes d'erreur MySQL transitoires
+var retryableMySQLErrors = map[uint16]bool{
+	1205: true, // ER_LOCK_WAIT_TIMEOUT
+	1213: true, // ER_LOCK_DEADLOCK
+}
+
+// retryableSQLServerErrors sont les codes d'erreur SQL Server transitoires
+var retryableSQLServerErrors = map[int32]bool{
+	1205: true, // deadlock victim
+	1222: true, // lock request time out
+}
+
+// IsRetryableError indique si une erreur est transitoire : échec de
+// sérialisation, deadlock, expiration d'un verrou ou perte de connexion, pour
+// PostgreSQL, MySQL et SQL Server
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return retryableSQLStates[pgErr.Code] || isConnectionError(err)
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return retryableMySQLErrors[mysqlErr.Number]
+	}
+
+	var mssqlErr mssql.Error
+	if errors.As(err, &mssqlErr) {
+		return retryableSQLServerErrors[mssqlErr.Number]
+	}
+
+	return isConnectionError(err)
+}
+
+// isConnectionError indique si err signale la perte de la connexion : la
+// connexion qui l'a renvoyée n'est plus utilisable
+func isConnectionError(err error) bool {
+	// Un délai dépassé ou une annulation se reproduirait à chaque tentative.
+	// context.DeadlineExceeded implémente net.Error : l'écarter avant.
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// Classe 08: connection_exception
+		return strings.HasPrefix(pgErr.Code, "08")
+	}
+
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		pgconn.SafeToRetry(err)
+}
+
+// withRetry exécute fn jusqu'à RetryAttempts fois tant que l'erreur renvoyée
+// est transitoire. fn doit ouvrir sa propre transaction : après une erreur,
+// PostgreSQL refuse toute instruction dans la transaction en cours, une
+// nouvelle tentative doit donc repartir d'une transaction neuve.
+//
+// En cas d'échec, failure est complétée avec l'erreur de chaque tentative puis
+// retournée. fn peut y renseigner la migration en cours.
+func (m *Migrator) withRetry(ctx context.Context, failure *MigrationError, fn func() error) error {
+	attempts := m.config.RetryAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		failure.Attempts = append(failure.Attempts, err)
+		failure.setCause(err)
+
+		if attempt >= attempts || !IsRetryableError(err) {
+			return failure
+		}
+		// Un Migrator lié à une connexion (TenantMigrator) ne peut pas en
+		// changer : une perte de connexion y est définitive
+		if _, pinned := m.db.ConnPool.(*sql.Conn); pinned && isConnectionError(err) {
+			return failure
+		}
+
+		timer := time.NewTimer(m.retryDelay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			failure.setCause(ctx.Err())
+			return failure
+		case <-timer.C:
+		}
+	}
+}
+
+// retryDelay calcule le délai avant la tentative suivante : un backoff
+// exponentiel borné par RetryMaxBackoff, avec une part aléatoire RetryJitter
+func (m *Migrator) retryDelay(attempt int) time.Duration {
+	delay := m.config.RetryBackoff
+	for i := 1; i < attempt && (m.config.RetryMaxBackoff <= 0 || delay < m.config.RetryMaxBackoff); i++ {
+		delay *= 2
+	}
+	if m.config.RetryMaxBackoff > 0 && delay > m.config.RetryMaxBackoff {
+		delay = m.config.RetryMaxBackoff
+	}
+
+	if jitter := m.config.RetryJitter; jitter > 0 {
+		delay = time.Duration(float64(delay) * (1 + jitter*(2*rand.Float64()-1)))
+	}
+	return delay
+}
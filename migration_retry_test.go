@@ -0,0 +1,108 @@
+package gormlib
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	mssql "github.com/microsoft/go-mssqldb"
+	"gorm.io/gorm"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"erreur quelconque", errors.New("boom"), false},
+		{"délai dépassé", context.DeadlineExceeded, false},
+		{"délai dépassé enveloppé", fmt.Errorf("migration: %w", context.DeadlineExceeded), false},
+		{"annulation", context.Canceled, false},
+		{"annulation enveloppée", fmt.Errorf("migration: %w", context.Canceled), false},
+		{"postgres serialization_failure", &pgconn.PgError{Code: "40001"}, true},
+		{"postgres deadlock_detected", &pgconn.PgError{Code: "40P01"}, true},
+		{"postgres lock_not_available", &pgconn.PgError{Code: "55P03"}, true},
+		{"postgres connection_failure", &pgconn.PgError{Code: "08006"}, true},
+		{"postgres unique_violation", &pgconn.PgError{Code: "23505"}, false},
+		{"postgres query_canceled", &pgconn.PgError{Code: "57014"}, false},
+		{"postgres enveloppée", fmt.Errorf("step: %w", &pgconn.PgError{Code: "40001"}), true},
+		{"mysql lock wait timeout", &mysql.MySQLError{Number: 1205}, true},
+		{"mysql deadlock", &mysql.MySQLError{Number: 1213}, true},
+		{"mysql duplicate entry", &mysql.MySQLError{Number: 1062}, false},
+		{"sqlserver deadlock victim", mssql.Error{Number: 1205}, true},
+		{"sqlserver lock timeout", mssql.Error{Number: 1222}, true},
+		{"sqlserver syntax error", mssql.Error{Number: 102}, false},
+		{"erreur réseau", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"connexion invalide", driver.ErrBadConn, true},
+		{"fin de flux inattendue", fmt.Errorf("read: %w", io.ErrUnexpectedEOF), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryableError(tt.err); got != tt.want {
+				t.Errorf("IsRetryableError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithRetryPinnedConnection(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+
+	conn, err := sqlDB.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	pinnedDB, err := gorm.Open(&sqlite.Dialector{Conn: conn}, &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		db   *gorm.DB
+		err  error
+		want int
+	}{
+		{"pool, perte de connexion", db, driver.ErrBadConn, 3},
+		{"connexion liée, perte de connexion", pinnedDB, driver.ErrBadConn, 1},
+		{"connexion liée, deadlock", pinnedDB, &pgconn.PgError{Code: "40P01"}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMigrator(tt.db, &MigrationConfig{RetryAttempts: 3, RetryBackoff: time.Millisecond})
+
+			attempts := 0
+			err := m.withRetry(context.Background(), &MigrationError{Op: "run migration"}, func() error {
+				attempts++
+				return tt.err
+			})
+			if err == nil {
+				t.Fatal("withRetry() n'a pas retourné d'erreur")
+			}
+			if attempts != tt.want {
+				t.Errorf("withRetry() = %d tentative(s), attendu %d", attempts, tt.want)
+			}
+		})
+	}
+}
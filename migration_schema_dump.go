@@ -0,0 +1,454 @@
+package gormlib
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Requêtes du catalogue PostgreSQL utilisées par le dump du schéma. Chaque
+// requête est triée pour que le dump ne change qu'avec le schéma.
+const (
+	dumpEnumsQuery = `SELECT t.typname AS name,
+	string_agg(quote_literal(e.enumlabel), ', ' ORDER BY e.enumsortorder) AS labels
+FROM pg_type t
+JOIN pg_enum e ON e.enumtypid = t.oid
+JOIN pg_namespace n ON n.oid = t.typnamespace
+WHERE n.nspname = ?
+GROUP BY t.typname
+ORDER BY t.typname`
+
+	// Les séquences des colonnes IDENTITY sont créées avec leur colonne
+	dumpSequencesQuery = `SELECT c.relname AS name, format_type(s.seqtypid, NULL) AS data_type,
+	s.seqstart AS start, s.seqincrement AS increment, s.seqmin AS min_value,
+	s.seqmax AS max_value, s.seqcache AS cache, s.seqcycle AS cycle
+FROM pg_sequence s
+JOIN pg_class c ON c.oid = s.seqrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = ? AND NOT EXISTS (
+	SELECT 1 FROM pg_depend d
+	WHERE d.classid = 'pg_class'::regclass AND d.objid = s.seqrelid AND d.deptype = 'i'
+)
+ORDER BY c.relname`
+
+	// Les fonctions des extensions sont créées par l'extension
+	dumpFunctionsQuery = `SELECT p.proname AS name, pg_get_function_identity_arguments(p.oid) AS arguments,
+	pg_get_functiondef(p.oid) AS definition
+FROM pg_proc p
+JOIN pg_namespace n ON n.oid = p.pronamespace
+WHERE n.nspname = ? AND p.prokind IN ('f', 'p') AND NOT EXISTS (
+	SELECT 1 FROM pg_depend d
+	WHERE d.classid = 'pg_proc'::regclass AND d.objid = p.oid AND d.deptype = 'e'
+)
+ORDER BY p.proname, arguments`
+
+	dumpTablesQuery = `SELECT c.relname AS name
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = ? AND c.relkind IN ('r', 'p')
+ORDER BY c.relname`
+
+	dumpColumnsQuery = `SELECT c.relname AS table_name, a.attname AS name,
+	format_type(a.atttypid, a.atttypmod) AS data_type, a.attnotnull AS not_null,
+	a.attidentity::text AS identity, a.attgenerated::text AS generated,
+	COALESCE(pg_get_expr(d.adbin, d.adrelid), '') AS default_value
+FROM pg_attribute a
+JOIN pg_class c ON c.oid = a.attrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+WHERE n.nspname = ? AND c.relkind IN ('r', 'p') AND a.attnum > 0 AND NOT a.attisdropped
+ORDER BY c.relname, a.attnum`
+
+	dumpConstraintsQuery = `SELECT c.relname AS table_name, con.conname AS name, con.contype::text AS type,
+	pg_get_constraintdef(con.oid, true) AS definition
+FROM pg_constraint con
+JOIN pg_class c ON c.oid = con.conrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = ? AND c.relkind IN ('r', 'p') AND con.contype IN ('p', 'u', 'c', 'x', 'f')
+ORDER BY c.relname, con.conname`
+
+	// Les index des clés primaires et des contraintes d'unicité ou
+	// d'exclusion sont créés par leur contrainte
+	dumpIndexesQuery = `SELECT t.relname AS table_name, i.relname AS name, pg_get_indexdef(i.oid) AS definition
+FROM pg_index x
+JOIN pg_class i ON i.oid = x.indexrelid
+JOIN pg_class t ON t.oid = x.indrelid
+JOIN pg_namespace n ON n.oid = t.relnamespace
+WHERE n.nspname = ? AND NOT EXISTS (
+	SELECT 1 FROM pg_constraint con
+	WHERE con.conrelid = t.oid AND con.conindid = i.oid AND con.contype IN ('p', 'u', 'x')
+)
+ORDER BY t.relname, i.relname`
+
+	dumpViewsQuery = `SELECT c.relname AS name, c.relkind::text AS kind, pg_get_viewdef(c.oid, true) AS definition
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = ? AND c.relkind IN ('v', 'm')
+ORDER BY c.relname`
+
+	dumpTriggersQuery = `SELECT c.relname AS table_name, t.tgname AS name, pg_get_triggerdef(t.oid, true) AS definition
+FROM pg_trigger t
+JOIN pg_class c ON c.oid = t.tgrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = ? AND NOT t.tgisinternal
+ORDER BY c.relname, t.tgname`
+)
+
+// historyDumpAppliedAt est la date d'application des migrations dans le dump
+const historyDumpAppliedAt = "1970-01-01 00:00:00+00"
+
+// dumpColumn est une colonne de table lue dans pg_attribute
+type dumpColumn struct {
+	TableName    string
+	Name         string
+	DataType     string
+	NotNull      bool
+	Identity     string // "a" (ALWAYS), "d" (BY DEFAULT) ou vide
+	Generated    string // "s" pour une colonne générée, vide sinon
+	DefaultValue string
+}
+
+// dumpObject est un objet du schéma défini par une instruction du catalogue
+// (contrainte, index, vue, déclencheur, fonction)
+type dumpObject struct {
+	TableName  string
+	Name       string
+	Type       string
+	Kind       string
+	Arguments  string
+	Definition string
+}
+
+// DumpSchema écrit dans w le DDL du schéma géré par les migrations, suivi du
+// contenu de la table d'historique. Le schéma est le schéma courant de la
+// connexion, c'est-à-dire Config.Schema ("public" par défaut).
+//
+// Le dump est construit à partir de pg_catalog, sans pg_dump, et trié par nom
+// d'objet : il ne change que si le schéma change, ce qui permet de versionner
+// un fichier schema.sql et de relire les changements de schéma dans les
+// demandes de fusion. Seul PostgreSQL est supporté.
+func (m *Migrator) DumpSchema(w io.Writer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.Timeout)
+	defer cancel()
+
+	return m.dumpSchema(ctx, w)
+}
+
+// writeSchemaDump réécrit MigrationConfig.SchemaDumpPath après une migration
+// ou un rollback réussi, si ce fichier est configuré
+func (m *Migrator) writeSchemaDump(ctx context.Context) error {
+	if m.config.SchemaDumpPath == "" {
+		return nil
+	}
+
+	// Le fichier n'est écrit que si le dump est complet
+	var buf bytes.Buffer
+	if err := m.dumpSchema(ctx, &buf); err != nil {
+		return err
+	}
+	if err := os.WriteFile(m.config.SchemaDumpPath, buf.Bytes(), DefaultFileMode); err != nil {
+		return NewMigrationError("dump schema", err)
+	}
+	return nil
+}
+
+// dumpSchema écrit le dump du schéma courant dans w
+func (m *Migrator) dumpSchema(ctx context.Context, w io.Writer) error {
+	if m.db.Dialector.Name() != DriverPostgres {
+		return NewMigrationError("dump schema", fmt.Errorf("le dump du schéma n'est supporté qu'avec PostgreSQL, pas %s", m.db.Dialector.Name()))
+	}
+
+	db := m.db.WithContext(ctx)
+	var schema string
+	if err := db.Raw("SELECT COALESCE(current_schema(), '')").Scan(&schema).Error; err != nil {
+		return NewMigrationError("dump schema", err)
+	}
+	if schema == "" {
+		return NewMigrationError("dump schema", fmt.Errorf("aucun schéma courant, vérifiez Config.Schema"))
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "-- Schéma %s généré par gormlib à partir du catalogue PostgreSQL.\n", quoteIdentifier(schema))
+	buf.WriteString("-- Ce fichier est réécrit après chaque migration : ne le modifiez pas.\n")
+
+	for _, section := range []func(*gorm.DB, string, *bytes.Buffer) error{
+		dumpEnums,
+		dumpSequences,
+		dumpFunctions,
+		dumpTables,
+		dumpIndexes,
+		dumpForeignKeys,
+		dumpViews,
+		dumpTriggers,
+		m.dumpHistory,
+	} {
+		if err := section(db, schema, &buf); err != nil {
+			return NewMigrationError("dump schema", err)
+		}
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return NewMigrationError("dump schema", err)
+	}
+	return nil
+}
+
+// dumpSection écrit le titre d'une section du dump
+func dumpSection(buf *bytes.Buffer, title string) {
+	fmt.Fprintf(buf, "\n--\n-- %s\n--\n\n", title)
+}
+
+func dumpEnums(db *gorm.DB, schema string, buf *bytes.Buffer) error {
+	var enums []struct {
+		Name   string
+		Labels string
+	}
+	if err := db.Raw(dumpEnumsQuery, schema).Scan(&enums).Error; err != nil {
+		return fmt.Errorf("types énumérés: %w", err)
+	}
+	if len(enums) == 0 {
+		return nil
+	}
+
+	dumpSection(buf, "Types énumérés")
+	for _, enum := range enums {
+		fmt.Fprintf(buf, "CREATE TYPE %s AS ENUM (%s);\n", quoteIdentifier(enum.Name), enum.Labels)
+	}
+	return nil
+}
+
+func dumpSequences(db *gorm.DB, schema string, buf *bytes.Buffer) error {
+	var sequences []struct {
+		Name      string
+		DataType  string
+		Start     int64
+		Increment int64
+		MinValue  int64
+		MaxValue  int64
+		Cache     int64
+		Cycle     bool
+	}
+	if err := db.Raw(dumpSequencesQuery, schema).Scan(&sequences).Error; err != nil {
+		return fmt.Errorf("séquences: %w", err)
+	}
+	if len(sequences) == 0 {
+		return nil
+	}
+
+	dumpSection(buf, "Séquences")
+	for _, s := range sequences {
+		cycle := ""
+		if s.Cycle {
+			cycle = " CYCLE"
+		}
+		fmt.Fprintf(buf, "CREATE SEQUENCE %s AS %s START WITH %d INCREMENT BY %d MINVALUE %d MAXVALUE %d CACHE %d%s;\n",
+			quoteIdentifier(s.Name), s.DataType, s.Start, s.Increment, s.MinValue, s.MaxValue, s.Cache, cycle)
+	}
+	return nil
+}
+
+func dumpFunctions(db *gorm.DB, schema string, buf *bytes.Buffer) error {
+	var functions []dumpObject
+	if err := db.Raw(dumpFunctionsQuery, schema).Scan(&functions).Error; err != nil {
+		return fmt.Errorf("fonctions: %w", err)
+	}
+	if len(functions) == 0 {
+		return nil
+	}
+
+	dumpSection(buf, "Fonctions")
+	for i, function := range functions {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(buf, "%s;\n", strings.TrimSpace(function.Definition))
+	}
+	return nil
+}
+
+func dumpTables(db *gorm.DB, schema string, buf *bytes.Buffer) error {
+	var tables []string
+	if err := db.Raw(dumpTablesQuery, schema).Scan(&tables).Error; err != nil {
+		return fmt.Errorf("tables: %w", err)
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+
+	var columns []dumpColumn
+	if err := db.Raw(dumpColumnsQuery, schema).Scan(&columns).Error; err != nil {
+		return fmt.Errorf("colonnes: %w", err)
+	}
+	var constraints []dumpObject
+	if err := db.Raw(dumpConstraintsQuery, schema).Scan(&constraints).Error; err != nil {
+		return fmt.Errorf("contraintes: %w", err)
+	}
+
+	// Les clés étrangères sont ajoutées après toutes les tables
+	lines := make(map[string][]string, len(tables))
+	for _, column := range columns {
+		lines[column.TableName] = append(lines[column.TableName], column.definition())
+	}
+	for _, constraint := range constraints {
+		if constraint.Type != "f" {
+			lines[constraint.TableName] = append(lines[constraint.TableName],
+				fmt.Sprintf("CONSTRAINT %s %s", quoteIdentifier(constraint.Name), constraint.Definition))
+		}
+	}
+
+	dumpSection(buf, "Tables")
+	for i, table := range tables {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(buf, "CREATE TABLE %s (\n", quoteIdentifier(table))
+		for j, line := range lines[table] {
+			separator := ","
+			if j == len(lines[table])-1 {
+				separator = ""
+			}
+			fmt.Fprintf(buf, "\t%s%s\n", line, separator)
+		}
+		buf.WriteString(");\n")
+	}
+	return nil
+}
+
+// definition retourne la définition SQL de la colonne dans CREATE TABLE
+func (c dumpColumn) definition() string {
+	definition := quoteIdentifier(c.Name) + " " + c.DataType
+	switch {
+	case c.Generated == "s":
+		definition += " GENERATED ALWAYS AS (" + c.DefaultValue + ") STORED"
+	case c.Identity == "a":
+		definition += " GENERATED ALWAYS AS IDENTITY"
+	case c.Identity == "d":
+		definition += " GENERATED BY DEFAULT AS IDENTITY"
+	case c.DefaultValue != "":
+		definition += " DEFAULT " + c.DefaultValue
+	}
+	if c.NotNull {
+		definition += " NOT NULL"
+	}
+	return definition
+}
+
+func dumpIndexes(db *gorm.DB, schema string, buf *bytes.Buffer) error {
+	var indexes []dumpObject
+	if err := db.Raw(dumpIndexesQuery, schema).Scan(&indexes).Error; err != nil {
+		return fmt.Errorf("index: %w", err)
+	}
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	dumpSection(buf, "Index")
+	for _, index := range indexes {
+		fmt.Fprintf(buf, "%s;\n", index.Definition)
+	}
+	return nil
+}
+
+func dumpForeignKeys(db *gorm.DB, schema string, buf *bytes.Buffer) error {
+	var constraints []dumpObject
+	if err := db.Raw(dumpConstraintsQuery, schema).Scan(&constraints).Error; err != nil {
+		return fmt.Errorf("clés étrangères: %w", err)
+	}
+
+	section := false
+	for _, constraint := range constraints {
+		if constraint.Type != "f" {
+			continue
+		}
+		if !section {
+			dumpSection(buf, "Clés étrangères")
+			section = true
+		}
+		fmt.Fprintf(buf, "ALTER TABLE %s ADD CONSTRAINT %s %s;\n",
+			quoteIdentifier(constraint.TableName), quoteIdentifier(constraint.Name), constraint.Definition)
+	}
+	return nil
+}
+
+func dumpViews(db *gorm.DB, schema string, buf *bytes.Buffer) error {
+	var views []dumpObject
+	if err := db.Raw(dumpViewsQuery, schema).Scan(&views).Error; err != nil {
+		return fmt.Errorf("vues: %w", err)
+	}
+	if len(views) == 0 {
+		return nil
+	}
+
+	dumpSection(buf, "Vues")
+	for i, view := range views {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		kind := "VIEW"
+		if view.Kind == "m" {
+			kind = "MATERIALIZED VIEW"
+		}
+		definition := strings.TrimSuffix(strings.TrimSpace(view.Definition), ";")
+		fmt.Fprintf(buf, "CREATE %s %s AS\n%s;\n", kind, quoteIdentifier(view.Name), definition)
+	}
+	return nil
+}
+
+func dumpTriggers(db *gorm.DB, schema string, buf *bytes.Buffer) error {
+	var triggers []dumpObject
+	if err := db.Raw(dumpTriggersQuery, schema).Scan(&triggers).Error; err != nil {
+		return fmt.Errorf("déclencheurs: %w", err)
+	}
+	if len(triggers) == 0 {
+		return nil
+	}
+
+	dumpSection(buf, "Déclencheurs")
+	for _, trigger := range triggers {
+		fmt.Fprintf(buf, "%s;\n", trigger.Definition)
+	}
+	return nil
+}
+
+// dumpHistory écrit les migrations appliquées, triées par nom. Les dates
+// d'application, propres à chaque base, sont remplacées par une date fixe
+// (historyDumpAppliedAt) pour que le dump reste déterministe et rejouable.
+func (m *Migrator) dumpHistory(db *gorm.DB, schema string, buf *bytes.Buffer) error {
+	exists, err := m.hasTable(db, m.historyTable())
+	if err != nil {
+		return fmt.Errorf("historique des migrations: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+
+	var records []MigrationRecord
+	if err := m.history(db).Select("name", "checksum").Order("name").Find(&records).Error; err != nil {
+		return fmt.Errorf("historique des migrations: %w", err)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	dumpSection(buf, "Historique des migrations")
+	fmt.Fprintf(buf, "INSERT INTO %s (name, applied_at, checksum) VALUES\n", db.Statement.Quote(m.historyTable()))
+	for i, record := range records {
+		separator := ","
+		if i == len(records)-1 {
+			separator = ";"
+		}
+		fmt.Fprintf(buf, "\t(%s, %s, %s)%s\n",
+			quoteLiteral(record.Name), quoteLiteral(historyDumpAppliedAt), quoteLiteral(record.Checksum), separator)
+	}
+	return nil
+}
+
+// quoteLiteral retourne value sous forme de chaîne SQL
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,72 @@
+package gormlib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// sqlNoTransactionDirective est le commentaire qui, présent sur sa propre ligne
+// dans le fichier up, exécute la migration SQL hors transaction
+const sqlNoTransactionDirective = "-- gormlib:no-transaction"
+
+// SQLMigration est une migration définie par une paire de fichiers SQL
+// (YYYYMMDDHHMMSS_name.up.sql / YYYYMMDDHHMMSS_name.down.sql)
+type SQLMigration struct {
+	name    string
+	up      string
+	down    string
+	hasDown bool
+}
+
+// NoTransaction indique si le fichier up contient la directive
+// "-- gormlib:no-transaction"
+func (m *SQLMigration) NoTransaction() bool {
+	for _, line := range strings.Split(m.up, "\n") {
+		if strings.TrimSpace(line) == sqlNoTransactionDirective {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSQLMigration crée une migration SQL à partir du contenu de ses fichiers.
+// Un contenu down vide signifie que la migration n'a pas de fichier down.
+func NewSQLMigration(name, up, down string) *SQLMigration {
+	return &SQLMigration{
+		name:    name,
+		up:      up,
+		down:    down,
+		hasDown: down != "",
+	}
+}
+
+// Up exécute les instructions du fichier up
+func (m *SQLMigration) Up(db *gorm.DB) error {
+	return db.Exec(m.up).Error
+}
+
+// Down exécute les instructions du fichier down
+func (m *SQLMigration) Down(db *gorm.DB) error {
+	if !m.hasDown {
+		return fmt.Errorf("la migration %s n'a pas de fichier %s", m.name, SQLDownSuffix)
+	}
+	return db.Exec(m.down).Error
+}
+
+// Name retourne le nom de la migration
+func (m *SQLMigration) Name() string {
+	return m.name
+}
+
+// Checksum retourne l'empreinte SHA-256 des fichiers up et down
+func (m *SQLMigration) Checksum() string {
+	h := sha256.New()
+	h.Write([]byte(m.up))
+	h.Write([]byte{0})
+	h.Write([]byte(m.down))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,96 @@
+package gormlib
+
+import (
+	"time"
+)
+
+// MigrationState représente l'état d'une migration par rapport à la base de données
+type MigrationState string
+
+const (
+	// MigrationStateApplied indique une migration appliquée
+	MigrationStateApplied MigrationState = "applied"
+
+	// MigrationStatePending indique une migration en attente
+	MigrationStatePending MigrationState = "pending"
+
+	// MigrationStateMissing indique une migration appliquée mais absente du code
+	MigrationStateMissing MigrationState = "missing"
+
+	// MigrationStateOutOfOrder indique une migration en attente plus ancienne
+	// qu'une migration déjà appliquée
+	MigrationStateOutOfOrder MigrationState = "out-of-order"
+)
+
+// MigrationStatus décrit l'état d'une migration
+type MigrationStatus struct {
+	Name      string         `json:"name"`
+	State     MigrationState `json:"state"`
+	AppliedAt *time.Time     `json:"applied_at,omitempty"`
+}
+
+// Status retourne l'état de chaque migration disponible, dans l'ordre fourni,
+// suivi des migrations appliquées qui ne sont plus présentes dans le code
+func (m *Migrator) Status(available []Migration) ([]MigrationStatus, error) {
+	applied, err := m.GetAppliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := m.GetPendingMigrations(available)
+	if err != nil {
+		return nil, err
+	}
+
+	appliedAt := make(map[string]time.Time, len(applied))
+	for _, record := range applied {
+		appliedAt[record.Name] = record.AppliedAt
+	}
+
+	pendingNames := make(map[string]bool, len(pending))
+	for _, migration := range pending {
+		pendingNames[migration.Name()] = true
+	}
+
+	// Une migration en attente placée avant la dernière migration appliquée
+	// sera exécutée hors de l'ordre prévu
+	lastApplied := -1
+	for i, migration := range available {
+		if !pendingNames[migration.Name()] {
+			lastApplied = i
+		}
+	}
+
+	statuses := make([]MigrationStatus, 0, len(available))
+	known := make(map[string]bool, len(available))
+	for i, migration := range available {
+		name := migration.Name()
+		known[name] = true
+
+		status := MigrationStatus{Name: name, State: MigrationStateApplied}
+		switch {
+		case pendingNames[name] && i < lastApplied:
+			status.State = MigrationStateOutOfOrder
+		case pendingNames[name]:
+			status.State = MigrationStatePending
+		default:
+			at := appliedAt[name]
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+
+	for _, record := range applied {
+		if known[record.Name] {
+			continue
+		}
+		at := record.AppliedAt
+		statuses = append(statuses, MigrationStatus{
+			Name:      record.Name,
+			State:     MigrationStateMissing,
+			AppliedAt: &at,
+		})
+	}
+
+	return statuses, nil
+}
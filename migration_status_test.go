@@ -0,0 +1,101 @@
+package gormlib
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		available []string
+		applied   []string
+		want      []MigrationStatus
+	}{
+		{
+			name:      "aucune migration appliquée",
+			available: []string{"001_a", "002_b"},
+			want: []MigrationStatus{
+				{Name: "001_a", State: MigrationStatePending},
+				{Name: "002_b", State: MigrationStatePending},
+			},
+		},
+		{
+			name:      "toutes appliquées",
+			available: []string{"001_a", "002_b"},
+			applied:   []string{"001_a", "002_b"},
+			want: []MigrationStatus{
+				{Name: "001_a", State: MigrationStateApplied},
+				{Name: "002_b", State: MigrationStateApplied},
+			},
+		},
+		{
+			name:      "en attente, hors ordre et manquante",
+			available: []string{"001_a", "002_b", "003_c", "004_d"},
+			applied:   []string{"001_a", "003_c", "000_removed"},
+			want: []MigrationStatus{
+				{Name: "001_a", State: MigrationStateApplied},
+				{Name: "002_b", State: MigrationStateOutOfOrder},
+				{Name: "003_c", State: MigrationStateApplied},
+				{Name: "004_d", State: MigrationStatePending},
+				{Name: "000_removed", State: MigrationStateMissing},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sqlDB, err := db.DB()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sqlDB.Close()
+			// Chaque connexion ouvre sa propre base en mémoire
+			sqlDB.SetMaxOpenConns(1)
+
+			m := NewMigrator(db, DefaultConfig())
+			if err := m.ensureHistoryTable(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			appliedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+			for _, name := range tt.applied {
+				if err := m.history(db).Create(&MigrationRecord{Name: name, AppliedAt: appliedAt}).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			available := make([]Migration, len(tt.available))
+			for i, name := range tt.available {
+				available[i] = &testMigration{name: name}
+			}
+
+			statuses, err := m.Status(available)
+			if err != nil {
+				t.Fatalf("Status() erreur = %v", err)
+			}
+			if len(statuses) != len(tt.want) {
+				t.Fatalf("Status() = %+v, attendu %+v", statuses, tt.want)
+			}
+			for i, status := range statuses {
+				want := tt.want[i]
+				if status.Name != want.Name || status.State != want.State {
+					t.Errorf("Status()[%d] = %s %s, attendu %s %s", i, status.Name, status.State, want.Name, want.State)
+				}
+				// Seules les migrations enregistrées ont une date d'application
+				applied := status.State == MigrationStateApplied || status.State == MigrationStateMissing
+				if (status.AppliedAt != nil) != applied {
+					t.Errorf("Status()[%d].AppliedAt = %v pour une migration %s", i, status.AppliedAt, status.State)
+				}
+			}
+		})
+	}
+}
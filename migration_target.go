@@ -0,0 +1,178 @@
+package gormlib
+
+import (
+	"context"
+	"fmt"
+)
+
+// MigrateTo applique ou annule les migrations jusqu'à ce que la base soit
+// exactement à la migration target : les migrations disponibles jusqu'à target
+// incluse sont appliquées, les suivantes sont annulées dans l'ordre inverse de
+// leur application.
+func (m *Migrator) MigrateTo(target string, available []Migration) error {
+	index := -1
+	for i, migration := range available {
+		if migration.Name() == target {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return NewMigrationError("migrate to", fmt.Errorf("migration %s introuvable", target))
+	}
+
+	return m.migrateTo(available, func(applied []MigrationRecord) ([]Migration, []Migration, error) {
+		keep := make(map[string]bool, index+1)
+		for _, migration := range available[:index+1] {
+			keep[migration.Name()] = true
+		}
+
+		reverts, err := m.selectReverts(applied, available, func(record MigrationRecord) bool {
+			return !keep[record.Name]
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return reverts, available[:index+1], nil
+	})
+}
+
+// RollbackSteps annule les n dernières migrations appliquées, dans l'ordre
+// inverse de leur application
+func (m *Migrator) RollbackSteps(n int, available []Migration) error {
+	if n <= 0 {
+		return nil
+	}
+
+	return m.migrateTo(available, func(applied []MigrationRecord) ([]Migration, []Migration, error) {
+		first := len(applied) - n
+		if first < 0 {
+			first = 0
+		}
+
+		reverts, err := m.selectReverts(applied[first:], available, func(MigrationRecord) bool { return true })
+		if err != nil {
+			return nil, nil, err
+		}
+		return reverts, nil, nil
+	})
+}
+
+// Reset annule toutes les migrations appliquées, dans l'ordre inverse de leur
+// application
+func (m *Migrator) Reset(available []Migration) error {
+	return m.migrateTo(available, func(applied []MigrationRecord) ([]Migration, []Migration, error) {
+		reverts, err := m.selectReverts(applied, available, func(MigrationRecord) bool { return true })
+		if err != nil {
+			return nil, nil, err
+		}
+		return reverts, nil, nil
+	})
+}
+
+// migrateTo calcule, à partir des migrations appliquées, les migrations à
+// annuler puis à appliquer, et les exécute en détenant le verrou de migration
+func (m *Migrator) migrateTo(available []Migration, plan func(applied []MigrationRecord) (reverts, applies []Migration, err error)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.Timeout)
+	defer cancel()
+
+	// En mode dry-run, afficher le SQL sans rien modifier
+	if m.config.DryRun {
+		applied, err := m.appliedMigrations(ctx)
+		if err != nil {
+			return err
+		}
+		reverts, applies, err := plan(applied)
+		if err != nil {
+			return err
+		}
+		return m.printTargetPlan(ctx, applied, reverts, applies)
+	}
+
+	return m.withLock(ctx, func() error {
+		if err := m.ensureHistoryTable(ctx); err != nil {
+			return err
+		}
+
+		if err := m.verifyChecksums(ctx, available); err != nil {
+			return err
+		}
+
+		applied, err := m.appliedMigrations(ctx)
+		if err != nil {
+			return err
+		}
+
+		reverts, applies, err := plan(applied)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range reverts {
+			if err := m.rollbackMigration(ctx, migration); err != nil {
+				return err
+			}
+		}
+
+		if err := m.applyMigrations(ctx, applies); err != nil {
+			return err
+		}
+		return m.writeSchemaDump(ctx)
+	})
+}
+
+// selectReverts retourne, dans l'ordre inverse de leur application, les
+// migrations appliquées retenues par selected
+func (m *Migrator) selectReverts(applied []MigrationRecord, available []Migration, selected func(MigrationRecord) bool) ([]Migration, error) {
+	byName := make(map[string]Migration, len(available))
+	for _, migration := range available {
+		byName[migration.Name()] = migration
+	}
+
+	var reverts []Migration
+	for i := len(applied) - 1; i >= 0; i-- {
+		record := applied[i]
+		if !selected(record) {
+			continue
+		}
+
+		migration, ok := byName[record.Name]
+		if !ok {
+			return nil, NewMigrationError("rollback migration",
+				fmt.Errorf("la migration appliquée %s est absente du code", record.Name))
+		}
+		reverts = append(reverts, migration)
+	}
+
+	return reverts, nil
+}
+
+// printTargetPlan affiche le SQL des migrations à annuler puis à appliquer
+func (m *Migrator) printTargetPlan(ctx context.Context, applied []MigrationRecord, reverts, applies []Migration) error {
+	isApplied := make(map[string]bool, len(applied))
+	for _, record := range applied {
+		isApplied[record.Name] = true
+	}
+
+	var plans []MigrationPlan
+	for _, migration := range reverts {
+		statements, err := m.captureStatements(ctx, migration.Down)
+		if err != nil {
+			return NewMigrationError("plan rollback "+migration.Name(), err)
+		}
+		plans = append(plans, MigrationPlan{Name: migration.Name(), Direction: DirectionDown, Statements: statements})
+	}
+
+	for _, migration := range applies {
+		if isApplied[migration.Name()] {
+			continue
+		}
+		statements, err := m.captureStatements(ctx, migration.Up)
+		if err != nil {
+			return NewMigrationError("plan migration "+migration.Name(), err)
+		}
+		plans = append(plans, MigrationPlan{Name: migration.Name(), Direction: DirectionUp, Statements: statements})
+	}
+
+	return m.printPlans(plans...)
+}
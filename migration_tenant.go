@@ -0,0 +1,218 @@
+package gormlib
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// TenantConfig désigne les schémas PostgreSQL migrés par un TenantMigrator.
+// Schemas est prioritaire sur Query, elle-même prioritaire sur Pattern.
+type TenantConfig struct {
+	// Schemas est la liste explicite des schémas à migrer
+	Schemas []string
+
+	// Query est une requête SQL dont la première colonne donne les schémas à
+	// migrer, par exemple "SELECT schema_name FROM tenants WHERE active"
+	Query string
+
+	// Pattern est un motif LIKE sur le nom des schémas de pg_namespace
+	// ("tenant_%"). Vide, tous les schémas sont migrés, sauf les schémas
+	// système, public et le schéma courant de la connexion (Config.Schema).
+	Pattern string
+
+	// Parallelism est le nombre de schémas migrés en même temps (0 = 1). En
+	// mode DryRun, les schémas sont toujours traités l'un après l'autre.
+	Parallelism int
+}
+
+// TenantResult est le résultat d'une opération sur le schéma d'un locataire
+type TenantResult struct {
+	Schema   string
+	Duration time.Duration
+	Err      error
+}
+
+// TenantMigrator applique les mêmes migrations à chaque schéma d'une base
+// PostgreSQL (un schéma par locataire). Chaque schéma est migré sur une
+// connexion dont le search_path est ce schéma : il a sa propre table
+// d'historique et son propre verrou de migration.
+type TenantMigrator struct {
+	db      *gorm.DB
+	config  *MigrationConfig
+	tenants *TenantConfig
+}
+
+// NewTenantMigrator crée un gestionnaire de migrations par schéma. db doit
+// être une connexion PostgreSQL.
+func NewTenantMigrator(db *gorm.DB, config *MigrationConfig, tenants *TenantConfig) *TenantMigrator {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if tenants == nil {
+		tenants = &TenantConfig{}
+	}
+	return &TenantMigrator{
+		// Les migrations s'exécutent toujours sur le primaire
+		db:      primaryDB(db),
+		config:  config,
+		tenants: tenants,
+	}
+}
+
+// Tenants retourne les schémas désignés par la configuration
+func (t *TenantMigrator) Tenants(ctx context.Context) ([]string, error) {
+	if t.db.Dialector.Name() != DriverPostgres {
+		return nil, NewMigrationError("list tenants", fmt.Errorf("un schéma par locataire n'est supporté qu'avec PostgreSQL, pas %s", t.db.Dialector.Name()))
+	}
+
+	var schemas []string
+	switch {
+	case len(t.tenants.Schemas) > 0:
+		schemas = t.tenants.Schemas
+	case t.tenants.Query != "":
+		if err := t.db.WithContext(ctx).Raw(t.tenants.Query).Scan(&schemas).Error; err != nil {
+			return nil, NewMigrationError("list tenants", err)
+		}
+	default:
+		// Sans motif, le schéma partagé (public ou Config.Schema) n'est pas
+		// un locataire
+		pattern := t.tenants.Pattern
+		all := pattern == ""
+		if all {
+			pattern = "%"
+		}
+		err := t.db.WithContext(ctx).Raw(`SELECT nspname FROM pg_namespace
+			WHERE nspname LIKE ? AND nspname NOT LIKE 'pg\_%' AND nspname <> 'information_schema'
+			  AND NOT (? AND (nspname = 'public' OR nspname = COALESCE(current_schema(), '')))
+			ORDER BY nspname`, pattern, all).Scan(&schemas).Error
+		if err != nil {
+			return nil, NewMigrationError("list tenants", err)
+		}
+	}
+
+	// Un schéma listé deux fois n'est migré qu'une fois
+	seen := make(map[string]bool, len(schemas))
+	tenants := make([]string, 0, len(schemas))
+	for _, name := range schemas {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			tenants = append(tenants, name)
+		}
+	}
+	return tenants, nil
+}
+
+// RunMigrations exécute les migrations non appliquées de chaque schéma
+func (t *TenantMigrator) RunMigrations(migrations ...Migration) ([]TenantResult, error) {
+	return t.ForEach(context.Background(), func(m *Migrator) error {
+		return m.RunMigrations(migrations...)
+	})
+}
+
+// ForEach exécute fn avec le Migrator de chaque schéma, jusqu'à
+// TenantConfig.Parallelism schémas en même temps. L'échec d'un schéma
+// n'interrompt pas les autres : le résultat de chacun est retourné dans
+// l'ordre des schémas, et l'erreur regroupe les échecs.
+func (t *TenantMigrator) ForEach(ctx context.Context, fn func(m *Migrator) error) ([]TenantResult, error) {
+	tenants, err := t.Tenants(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parallelism := max(t.tenants.Parallelism, 1)
+	if t.config.DryRun {
+		// Le SQL de plusieurs schémas ne doit pas s'entremêler
+		parallelism = 1
+	}
+
+	results := make([]TenantResult, len(tenants))
+	semaphore := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, tenant := range tenants {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			start := time.Now()
+			err := t.withTenant(ctx, tenant, fn)
+			results[i] = TenantResult{Schema: tenant, Duration: time.Since(start), Err: err}
+		}()
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("schéma %s: %w", result.Schema, result.Err))
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// withTenant exécute fn avec un Migrator lié à une connexion dont le
+// search_path est le schéma tenant
+func (t *TenantMigrator) withTenant(ctx context.Context, tenant string, fn func(m *Migrator) error) error {
+	sqlDB, err := t.db.DB()
+	if err != nil {
+		return err
+	}
+
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// La connexion retourne au pool avec le search_path par défaut, ou
+		// est écartée s'il n'a pas pu être rétabli
+		resetCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if _, err := conn.ExecContext(resetCtx, "RESET search_path"); err != nil {
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}()
+
+	var exists bool
+	if err := conn.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = $1)", tenant).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("le schéma %q n'existe pas", tenant)
+	}
+
+	if _, err := conn.ExecContext(ctx, "SET search_path TO "+quoteIdentifier(tenant)); err != nil {
+		return err
+	}
+
+	db, err := t.tenantDB(conn, tenant)
+	if err != nil {
+		return err
+	}
+	// Les schémas des locataires sont identiques : un seul dump serait
+	// réécrit par chacun d'eux
+	config := *t.config
+	config.SchemaDumpPath = ""
+	return fn(NewMigrator(db, &config))
+}
+
+// tenantDB retourne une instance de GORM utilisant conn, dont les tables des
+// modèles sont préfixées par le schéma tenant
+func (t *TenantMigrator) tenantDB(conn *sql.Conn, tenant string) (*gorm.DB, error) {
+	return gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{
+		Logger:               t.db.Logger,
+		NowFunc:              t.db.NowFunc,
+		NamingStrategy:       schema.NamingStrategy{TablePrefix: tenant + "."},
+		DisableAutomaticPing: true,
+	})
+}
@@ -0,0 +1,83 @@
+package gormlib
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationContext retourne le contexte d'exécution d'une migration, borné par
+// son propre délai (MigrationWithTimeout) ou par MigrationConfig.MigrationTimeout
+func (m *Migrator) migrationContext(ctx context.Context, migration Migration) (context.Context, context.CancelFunc) {
+	timeout := m.config.MigrationTimeout
+	if mt, ok := asMigration[MigrationWithTimeout](migration); ok {
+		timeout = mt.Timeout()
+	}
+
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// runStep exécute une étape (Up ou Down) d'une migration avec son délai et les
+// timeouts de session configurés. local indique que db est une transaction :
+// les timeouts ne s'appliquent alors qu'à celle-ci.
+func (m *Migrator) runStep(ctx context.Context, db *gorm.DB, migration Migration, step func(*gorm.DB) error, local bool) error {
+	stepCtx, cancel := m.migrationContext(ctx, migration)
+	defer cancel()
+
+	db = db.WithContext(stepCtx)
+	if err := m.setSessionTimeouts(db, local); err != nil {
+		return err
+	}
+
+	return step(db)
+}
+
+// setSessionTimeouts applique statement_timeout et lock_timeout à la session
+// (ou à la transaction si local) selon la configuration. Ces timeouts ne sont
+// appliqués que sur PostgreSQL.
+func (m *Migrator) setSessionTimeouts(db *gorm.DB, local bool) error {
+	if !m.dialect().sessionTimeouts() {
+		return nil
+	}
+
+	settings := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"statement_timeout", m.config.StatementTimeout},
+		{"lock_timeout", m.config.LockTimeout},
+	}
+
+	for _, setting := range settings {
+		if setting.value <= 0 {
+			continue
+		}
+		value := fmt.Sprintf("%dms", setting.value.Milliseconds())
+		if err := db.Exec("SELECT set_config(?, ?, ?)", setting.name, value, local).Error; err != nil {
+			return fmt.Errorf("configuration de %s: %w", setting.name, err)
+		}
+	}
+	return nil
+}
+
+// resetSessionTimeouts rétablit les timeouts par défaut de la session
+func (m *Migrator) resetSessionTimeouts(conn *gorm.DB) {
+	if !m.dialect().sessionTimeouts() {
+		return
+	}
+
+	// La connexion est rendue au pool même si le contexte de la migration a expiré
+	db := conn.WithContext(context.WithoutCancel(conn.Statement.Context))
+
+	if m.config.StatementTimeout > 0 {
+		db.Exec("RESET statement_timeout")
+	}
+	if m.config.LockTimeout > 0 {
+		db.Exec("RESET lock_timeout")
+	}
+}
@@ -0,0 +1,156 @@
+package gormlib
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationGroup est un ensemble de migrations exécutées ensemble, dans une
+// transaction ou non
+type migrationGroup struct {
+	migrations    []Migration
+	transactional bool
+}
+
+// isNonTransactional indique si une migration doit s'exécuter hors transaction
+func (m *Migrator) isNonTransactional(migration Migration) bool {
+	if m.config.TransactionMode == TransactionNone {
+		return true
+	}
+	if nt, ok := asMigration[NonTransactionalMigration](migration); ok {
+		return nt.NoTransaction()
+	}
+	return false
+}
+
+// transactionGroups regroupe les migrations selon TransactionMode. Une
+// migration non transactionnelle forme toujours un groupe à elle seule.
+func (m *Migrator) transactionGroups(migrations []Migration) []migrationGroup {
+	size := m.config.BatchSize
+	switch m.config.TransactionMode {
+	case TransactionPerMigration:
+		size = 1
+	case TransactionAll:
+		size = len(migrations)
+	}
+	if size < 1 {
+		size = 1
+	}
+
+	var groups []migrationGroup
+	var current []Migration
+	flush := func() {
+		if len(current) > 0 {
+			groups = append(groups, migrationGroup{migrations: current, transactional: true})
+			current = nil
+		}
+	}
+
+	for _, migration := range migrations {
+		if m.isNonTransactional(migration) {
+			flush()
+			groups = append(groups, migrationGroup{migrations: []Migration{migration}})
+			continue
+		}
+
+		current = append(current, migration)
+		if len(current) >= size {
+			flush()
+		}
+	}
+	flush()
+
+	return groups
+}
+
+// withConnection exécute fn sur une connexion dédiée du pool, afin que toutes
+// les instructions d'une migration non transactionnelle partagent la même
+// session. Un Migrator lié à une connexion l'utilise directement.
+func (m *Migrator) withConnection(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	if _, ok := m.db.ConnPool.(*sql.Conn); ok {
+		return fn(m.db.WithContext(ctx))
+	}
+	return m.db.WithContext(ctx).Connection(fn)
+}
+
+// runWithoutTransaction exécute une migration hors transaction puis
+// l'enregistre dès qu'elle a réussi
+func (m *Migrator) runWithoutTransaction(ctx context.Context, migration Migration) error {
+	db := m.db.WithContext(ctx)
+
+	// Vérifier si la migration a déjà été appliquée
+	var count int64
+	if err := m.history(db).Where("name = ?", migration.Name()).Count(&count).Error; err != nil {
+		return newMigrationError(ErrMigrationFailed, "run migration", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	failure := newMigrationError(ErrMigrationFailed, "run migration", nil)
+	failure.Migration = migration.Name()
+	failure.Direction = DirectionUp
+
+	// Chaque tentative prend sa propre connexion : après une perte de
+	// connexion, la précédente n'est plus utilisable
+	err := m.withRetry(ctx, failure, func() error {
+		return m.withConnection(ctx, func(conn *gorm.DB) error {
+			// Les timeouts de session ne doivent pas rester sur la connexion
+			// rendue au pool
+			defer m.resetSessionTimeouts(conn)
+			return m.runStep(ctx, conn, migration, migration.Up, false)
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	record := MigrationRecord{
+		Name:      migration.Name(),
+		AppliedAt: time.Now(),
+		Checksum:  migrationChecksum(migration),
+	}
+	if err := m.history(db).Create(&record).Error; err != nil {
+		failure := newMigrationError(ErrMigrationFailed, "record migration", err)
+		failure.Migration = migration.Name()
+		failure.Direction = DirectionUp
+		return failure
+	}
+	return nil
+}
+
+// rollbackWithoutTransaction annule une migration hors transaction puis
+// supprime son enregistrement
+func (m *Migrator) rollbackWithoutTransaction(ctx context.Context, migration Migration) error {
+	db := m.db.WithContext(ctx)
+
+	var record MigrationRecord
+	if err := m.history(db).Where("name = ?", migration.Name()).First(&record).Error; err != nil {
+		return migrationNotFound(migration)
+	}
+
+	failure := newMigrationError(ErrRollbackFailed, "rollback migration", nil)
+	failure.Migration = migration.Name()
+	failure.Direction = DirectionDown
+
+	err := m.withRetry(ctx, failure, func() error {
+		return m.withConnection(ctx, func(conn *gorm.DB) error {
+			defer m.resetSessionTimeouts(conn)
+			return m.runStep(ctx, conn, migration, migration.Down, false)
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := m.history(db).Delete(&record).Error; err != nil {
+		failure := newMigrationError(ErrRollbackFailed, "delete migration record", err)
+		failure.Migration = migration.Name()
+		failure.Direction = DirectionDown
+		return failure
+	}
+	return nil
+}
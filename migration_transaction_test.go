@@ -0,0 +1,80 @@
+package gormlib
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// testMigration est une migration sans effet, transactionnelle sauf si
+// noTransaction est vrai
+type testMigration struct {
+	name          string
+	noTransaction bool
+}
+
+func (m *testMigration) Up(*gorm.DB) error   { return nil }
+func (m *testMigration) Down(*gorm.DB) error { return nil }
+func (m *testMigration) Name() string        { return m.name }
+func (m *testMigration) NoTransaction() bool { return m.noTransaction }
+
+// testMigrations crée une migration par lettre de spec : "t" transactionnelle,
+// "n" non transactionnelle
+func testMigrations(spec string) []Migration {
+	migrations := make([]Migration, len(spec))
+	for i, kind := range spec {
+		migrations[i] = &testMigration{name: fmt.Sprintf("%c%d", kind, i), noTransaction: kind == 'n'}
+	}
+	return migrations
+}
+
+// describeGroups résume les groupes : "[t0 t1]" pour une transaction,
+// "(n2)" hors transaction
+func describeGroups(groups []migrationGroup) string {
+	var parts []string
+	for _, group := range groups {
+		names := make([]string, len(group.migrations))
+		for i, migration := range group.migrations {
+			names[i] = migration.Name()
+		}
+		if group.transactional {
+			parts = append(parts, "["+strings.Join(names, " ")+"]")
+		} else {
+			parts = append(parts, "("+strings.Join(names, " ")+")")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestTransactionGroups(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      TransactionMode
+		batchSize int
+		spec      string
+		want      string
+	}{
+		{"par lot", TransactionPerBatch, 2, "ttttt", "[t0 t1] [t2 t3] [t4]"},
+		{"lot de taille nulle", TransactionPerBatch, 0, "tt", "[t0] [t1]"},
+		{"par migration", TransactionPerMigration, 10, "ttt", "[t0] [t1] [t2]"},
+		{"tout en un", TransactionAll, 1, "tttt", "[t0 t1 t2 t3]"},
+		{"sans transaction", TransactionNone, 10, "tt", "(t0) (t1)"},
+		{"non transactionnelle au milieu d'un lot", TransactionPerBatch, 3, "ttntt", "[t0 t1] (n2) [t3 t4]"},
+		{"non transactionnelle avec tout en un", TransactionAll, 0, "tntt", "[t0] (n1) [t2 t3]"},
+		{"non transactionnelles consécutives", TransactionPerBatch, 5, "nnt", "(n0) (n1) [t2]"},
+		{"aucune migration", TransactionAll, 0, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Migrator{config: &MigrationConfig{TransactionMode: tt.mode, BatchSize: tt.batchSize}}
+
+			got := describeGroups(m.transactionGroups(testMigrations(tt.spec)))
+			if got != tt.want {
+				t.Errorf("transactionGroups(%q) = %s, attendu %s", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,9 +2,15 @@ package gormlib
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
 // Migrator gère les migrations de la base de données
@@ -19,30 +25,64 @@ func NewMigrator(db *gorm.DB, config *MigrationConfig) *Migrator {
 		config = DefaultConfig()
 	}
 	return &Migrator{
-		db:     db,
+		// Les migrations s'exécutent toujours sur le primaire
+		db:     primaryDB(db),
 		config: config,
 	}
 }
 
-// RunMigrations exécute toutes les migrations non appliquées
+// RunMigrations exécute toutes les migrations non appliquées, en au plus
+// MigrationConfig.Timeout. Un verrou consultatif empêche plusieurs instances
+// de migrer en même temps.
 func (m *Migrator) RunMigrations(migrations ...Migration) error {
 	ctx, cancel := context.WithTimeout(context.Background(), m.config.Timeout)
 	defer cancel()
 
-	// Créer la table des migrations si elle n'existe pas
-	if err := m.db.AutoMigrate(&MigrationRecord{}); err != nil {
-		return NewMigrationError("create migrations table", err)
+	return m.RunMigrationsContext(ctx, migrations...)
+}
+
+// RunMigrationsContext exécute toutes les migrations non appliquées.
+// L'annulation de ctx interrompt la migration en cours et les suivantes.
+func (m *Migrator) RunMigrationsContext(ctx context.Context, migrations ...Migration) error {
+	// En mode dry-run, afficher le SQL sans rien modifier
+	if m.config.DryRun {
+		plans, err := m.planMigrations(ctx, migrations)
+		if err != nil {
+			return err
+		}
+		return m.printPlans(plans...)
 	}
 
-	// Exécuter les migrations par lots
-	for i := 0; i < len(migrations); i += m.config.BatchSize {
-		end := i + m.config.BatchSize
-		if end > len(migrations) {
-			end = len(migrations)
+	return m.withLock(ctx, func() error {
+		// Créer la table des migrations si elle n'existe pas
+		if err := m.ensureHistoryTable(ctx); err != nil {
+			return err
+		}
+
+		// Refuser de migrer si une migration appliquée a été modifiée
+		if err := m.verifyChecksums(ctx, migrations); err != nil {
+			return err
+		}
+
+		if err := m.applyMigrations(ctx, migrations); err != nil {
+			return err
+		}
+		return m.writeSchemaDump(ctx)
+	})
+}
+
+// applyMigrations exécute les migrations non appliquées, regroupées en
+// transactions selon TransactionMode
+func (m *Migrator) applyMigrations(ctx context.Context, migrations []Migration) error {
+	for _, group := range m.transactionGroups(migrations) {
+		if !group.transactional {
+			if err := m.runWithoutTransaction(ctx, group.migrations[0]); err != nil {
+				return err
+			}
+			continue
 		}
 
-		batch := migrations[i:end]
-		if err := m.runMigrationBatch(ctx, batch); err != nil {
+		if err := m.runMigrationBatch(ctx, group.migrations); err != nil {
 			return err
 		}
 	}
@@ -50,39 +90,223 @@ func (m *Migrator) RunMigrations(migrations ...Migration) error {
 	return nil
 }
 
-// runMigrationBatch exécute un lot de migrations dans une transaction
-func (m *Migrator) runMigrationBatch(ctx context.Context, migrations []Migration) error {
-	return m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		for _, migration := range migrations {
-			// Vérifier si la migration a déjà été appliquée
-			var record MigrationRecord
-			result := tx.Where("name = ?", migration.Name()).First(&record)
-			if result.Error == nil {
-				continue
-			}
+// history retourne une session GORM ciblant la table d'historique configurée
+func (m *Migrator) history(db *gorm.DB) *gorm.DB {
+	return db.Table(m.historyTable())
+}
+
+// historyTable retourne le nom de la table d'historique. Avec SQL Server, elle
+// est qualifiée par Config.Schema comme les tables des modèles (TablePrefix) :
+// sans cela, elle serait créée dans le schéma par défaut de l'utilisateur.
+func (m *Migrator) historyTable() string {
+	return m.qualifiedTable(m.config.TableName)
+}
+
+// qualifiedTable qualifie une table non qualifiée par le schéma des modèles,
+// pour SQL Server. PostgreSQL trouve ses tables par le search_path.
+func (m *Migrator) qualifiedTable(table string) string {
+	if m.db.Dialector.Name() != DriverSQLServer || table == "" || strings.Contains(table, ".") {
+		return table
+	}
+	if naming, ok := m.db.NamingStrategy.(schema.NamingStrategy); ok && strings.HasSuffix(naming.TablePrefix, ".") {
+		return naming.TablePrefix + table
+	}
+	return table
+}
+
+// splitTable sépare le schéma et le nom d'une table qualifiée
+func splitTable(table string) (tableSchema, name string) {
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		return table[:i], table[i+1:]
+	}
+	return "", table
+}
+
+// hasTable indique si une table existe. Le Migrator GORM de SQL Server cherche
+// une table qualifiée sous son nom complet et ne la trouve jamais.
+func (m *Migrator) hasTable(db *gorm.DB, table string) (bool, error) {
+	tableSchema, name := splitTable(table)
+	if m.db.Dialector.Name() != DriverSQLServer || tableSchema == "" {
+		return db.Migrator().HasTable(table), nil
+	}
+
+	var count int64
+	err := db.Raw("SELECT COUNT(*) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND TABLE_TYPE = 'BASE TABLE'",
+		tableSchema, name).Scan(&count).Error
+	return count > 0, err
+}
+
+// migrateHistoryTable crée la table d'historique ou lui ajoute les colonnes
+// manquantes
+func (m *Migrator) migrateHistoryTable(db *gorm.DB, exists bool) error {
+	tableSchema, name := splitTable(m.historyTable())
+	if m.db.Dialector.Name() != DriverSQLServer || tableSchema == "" {
+		return m.history(db).AutoMigrate(&MigrationRecord{})
+	}
+
+	// AutoMigrate recréerait la table qualifiée, introuvable pour GORM
+	if !exists {
+		return m.history(db).Migrator().CreateTable(&MigrationRecord{})
+	}
+
+	var columns []string
+	err := db.Raw("SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?",
+		tableSchema, name).Scan(&columns).Error
+	if err != nil {
+		return err
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&MigrationRecord{}); err != nil {
+		return err
+	}
+	for _, dbName := range stmt.Schema.DBNames {
+		if slices.ContainsFunc(columns, func(column string) bool { return strings.EqualFold(column, dbName) }) {
+			continue
+		}
+		if err := m.history(db).Migrator().AddColumn(&MigrationRecord{}, dbName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureHistoryTable crée la table d'historique si besoin. Lors de sa création,
+// l'historique de l'ancienne table (LegacyTableName) est importé s'il existe.
+func (m *Migrator) ensureHistoryTable(ctx context.Context) error {
+	db := m.db.WithContext(ctx)
+	table := m.historyTable()
+	exists, err := m.hasTable(db, table)
+	if err != nil {
+		return NewMigrationError("create migrations table", err)
+	}
+
+	if err := m.migrateHistoryTable(db, exists); err != nil {
+		return NewMigrationError("create migrations table", err)
+	}
+
+	legacy := m.qualifiedTable(m.config.LegacyTableName)
+	if exists || legacy == "" || legacy == table {
+		return nil
+	}
+	legacyExists, err := m.hasTable(db, legacy)
+	if err != nil {
+		return NewMigrationError("import legacy migrations table", err)
+	}
+	if !legacyExists {
+		return nil
+	}
+
+	err = db.Exec("INSERT INTO ? (name, applied_at) SELECT name, applied_at FROM ? ORDER BY id",
+		clause.Table{Name: table}, clause.Table{Name: legacy}).Error
+	if err != nil {
+		return NewMigrationError("import legacy migrations table", err)
+	}
+	return nil
+}
 
-			// Exécuter la migration avec retry
-			var err error
-			for attempt := 0; attempt < m.config.RetryAttempts; attempt++ {
-				if err = migration.Up(tx); err == nil {
-					break
+// verifyChecksums compare la somme de contrôle des migrations appliquées avec
+// celle enregistrée lors de leur application
+func (m *Migrator) verifyChecksums(ctx context.Context, migrations []Migration) error {
+	var records []MigrationRecord
+	if err := m.history(m.db.WithContext(ctx)).Where("checksum <> ''").Find(&records).Error; err != nil {
+		return NewMigrationError("verify checksums", err)
+	}
+
+	recorded := make(map[string]string, len(records))
+	for _, r := range records {
+		recorded[r.Name] = r.Checksum
+	}
+
+	var drifted []string
+	for _, migration := range migrations {
+		expected, ok := recorded[migration.Name()]
+		if !ok {
+			continue
+		}
+		if actual := migrationChecksum(migration); actual != "" && actual != expected {
+			drifted = append(drifted, migration.Name())
+		}
+	}
+
+	if len(drifted) > 0 {
+		return newMigrationError(ErrChecksumMismatch, "verify checksums", fmt.Errorf(
+			"%d migration(s) modifiée(s) après leur application: %s (utilisez -repair pour réenregistrer les sommes de contrôle)",
+			len(drifted), strings.Join(drifted, ", ")))
+	}
+	return nil
+}
+
+// RepairChecksums réenregistre la somme de contrôle actuelle des migrations
+// déjà appliquées. Retourne le nombre d'enregistrements mis à jour.
+func (m *Migrator) RepairChecksums(migrations ...Migration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.Timeout)
+	defer cancel()
+
+	var updated int
+	err := m.withLock(ctx, func() error {
+		if err := m.ensureHistoryTable(ctx); err != nil {
+			return err
+		}
+
+		return m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			for _, migration := range migrations {
+				checksum := migrationChecksum(migration)
+				if checksum == "" {
+					continue
 				}
-				time.Sleep(time.Second * time.Duration(attempt+1))
-			}
-			if err != nil {
-				return NewMigrationError("run migration", err)
-			}
 
-			// Enregistrer la migration
-			record = MigrationRecord{
-				Name:      migration.Name(),
-				AppliedAt: time.Now(),
+				result := m.history(tx).
+					Where("name = ? AND (checksum IS NULL OR checksum <> ?)", migration.Name(), checksum).
+					Update("checksum", checksum)
+				if result.Error != nil {
+					return NewMigrationError("repair checksums", result.Error)
+				}
+				updated += int(result.RowsAffected)
 			}
-			if err := tx.Create(&record).Error; err != nil {
-				return NewMigrationError("record migration", err)
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
+
+// runMigrationBatch exécute un lot de migrations dans une transaction. En cas
+// d'erreur transitoire, le lot est rejoué dans une nouvelle transaction.
+func (m *Migrator) runMigrationBatch(ctx context.Context, migrations []Migration) error {
+	failure := newMigrationError(ErrMigrationFailed, "run migration", nil)
+	failure.Direction = DirectionUp
+
+	return m.withRetry(ctx, failure, func() error {
+		return m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			for _, migration := range migrations {
+				failure.Migration = migration.Name()
+
+				// Vérifier si la migration a déjà été appliquée
+				var record MigrationRecord
+				result := m.history(tx).Where("name = ?", migration.Name()).First(&record)
+				if result.Error == nil {
+					continue
+				}
+
+				if err := m.runStep(ctx, tx, migration, migration.Up, true); err != nil {
+					return err
+				}
+
+				// Enregistrer la migration
+				record = MigrationRecord{
+					Name:      migration.Name(),
+					AppliedAt: time.Now(),
+					Checksum:  migrationChecksum(migration),
+				}
+				if err := m.history(tx).Create(&record).Error; err != nil {
+					return fmt.Errorf("enregistrement de la migration: %w", err)
+				}
 			}
-		}
-		return nil
+			return nil
+		})
 	})
 }
 
@@ -91,38 +315,88 @@ func (m *Migrator) RollbackMigration(migration Migration) error {
 	ctx, cancel := context.WithTimeout(context.Background(), m.config.Timeout)
 	defer cancel()
 
-	return m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// Vérifier si la migration existe
-		var record MigrationRecord
-		if err := tx.Where("name = ?", migration.Name()).First(&record).Error; err != nil {
-			return ErrMigrationNotFound
-		}
+	return m.RollbackMigrationContext(ctx, migration)
+}
 
-		// Exécuter le rollback avec retry
-		var err error
-		for attempt := 0; attempt < m.config.RetryAttempts; attempt++ {
-			if err = migration.Down(tx); err == nil {
-				break
-			}
-			time.Sleep(time.Second * time.Duration(attempt+1))
-		}
+// RollbackMigrationContext annule une migration appliquée. L'annulation de ctx
+// interrompt le rollback.
+func (m *Migrator) RollbackMigrationContext(ctx context.Context, migration Migration) error {
+	// En mode dry-run, afficher le SQL sans rien modifier
+	if m.config.DryRun {
+		plan, err := m.planRollback(ctx, migration)
 		if err != nil {
-			return NewMigrationError("rollback migration", err)
+			return err
 		}
+		return m.printPlans(*plan)
+	}
 
-		// Supprimer l'enregistrement de la migration
-		if err := tx.Delete(&record).Error; err != nil {
-			return NewMigrationError("delete migration record", err)
+	return m.withLock(ctx, func() error {
+		if err := m.rollbackMigration(ctx, migration); err != nil {
+			return err
 		}
+		return m.writeSchemaDump(ctx)
+	})
+}
 
-		return nil
+// rollbackMigration annule une migration appliquée dans une transaction. En
+// cas d'erreur transitoire, le rollback est rejoué dans une nouvelle transaction.
+func (m *Migrator) rollbackMigration(ctx context.Context, migration Migration) error {
+	if m.isNonTransactional(migration) {
+		return m.rollbackWithoutTransaction(ctx, migration)
+	}
+
+	failure := newMigrationError(ErrRollbackFailed, "rollback migration", nil)
+	failure.Migration = migration.Name()
+	failure.Direction = DirectionDown
+
+	err := m.withRetry(ctx, failure, func() error {
+		return m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			// Vérifier si la migration existe
+			var record MigrationRecord
+			if err := m.history(tx).Where("name = ?", migration.Name()).First(&record).Error; err != nil {
+				return ErrMigrationNotFound
+			}
+
+			if err := m.runStep(ctx, tx, migration, migration.Down, true); err != nil {
+				return err
+			}
+
+			// Supprimer l'enregistrement de la migration
+			if err := m.history(tx).Delete(&record).Error; err != nil {
+				return fmt.Errorf("suppression de l'enregistrement de la migration: %w", err)
+			}
+
+			return nil
+		})
 	})
+
+	// Une migration non appliquée n'est pas un échec du rollback
+	if errors.Is(err, ErrMigrationNotFound) {
+		return migrationNotFound(migration)
+	}
+	return err
 }
 
 // GetAppliedMigrations retourne la liste des migrations appliquées
 func (m *Migrator) GetAppliedMigrations() ([]MigrationRecord, error) {
+	return m.appliedMigrations(context.Background())
+}
+
+// appliedMigrations retourne les migrations appliquées, par ordre d'application
+func (m *Migrator) appliedMigrations(ctx context.Context) ([]MigrationRecord, error) {
+	db := m.db.WithContext(ctx)
+
+	// Sans table d'historique, aucune migration n'a été appliquée
+	exists, err := m.hasTable(db, m.historyTable())
+	if err != nil {
+		return nil, NewMigrationError("get applied migrations", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+
 	var migrations []MigrationRecord
-	if err := m.db.Order("applied_at").Find(&migrations).Error; err != nil {
+	if err := m.history(db).Order("applied_at, id").Find(&migrations).Error; err != nil {
 		return nil, NewMigrationError("get applied migrations", err)
 	}
 	return migrations, nil
@@ -130,7 +404,12 @@ func (m *Migrator) GetAppliedMigrations() ([]MigrationRecord, error) {
 
 // GetPendingMigrations retourne la liste des migrations en attente
 func (m *Migrator) GetPendingMigrations(availableMigrations []Migration) ([]Migration, error) {
-	applied, err := m.GetAppliedMigrations()
+	return m.pendingMigrations(context.Background(), availableMigrations)
+}
+
+// pendingMigrations retourne les migrations disponibles non encore appliquées
+func (m *Migrator) pendingMigrations(ctx context.Context, availableMigrations []Migration) ([]Migration, error) {
+	applied, err := m.appliedMigrations(ctx)
 	if err != nil {
 		return nil, err
 	}
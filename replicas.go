@@ -0,0 +1,382 @@
+package gormlib
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
+)
+
+// Politiques de répartition des lectures entre les réplicas
+const (
+	ReplicaPolicyRandom     = "random"
+	ReplicaPolicyRoundRobin = "round-robin"
+	ReplicaPolicyLeastConn  = "least-conn"
+)
+
+// replicaPolicies sont les politiques de répartition reconnues
+var replicaPolicies = map[string]bool{
+	"":                      true,
+	ReplicaPolicyRandom:     true,
+	ReplicaPolicyRoundRobin: true,
+	ReplicaPolicyLeastConn:  true,
+}
+
+// replicaCheckInterval est l'intervalle entre deux vérifications de la
+// disponibilité et du retard des réplicas
+const replicaCheckInterval = 5 * time.Second
+
+// primaryPluginName est le nom du plugin qui rattache la connexion primaire
+// à une connexion répartie entre primaire et réplicas
+const primaryPluginName = "gormlib:primary"
+
+// primaryPlugin donne accès à la connexion primaire depuis la connexion
+// répartie, afin que les migrations ne s'exécutent jamais sur un réplica
+type primaryPlugin struct {
+	db *gorm.DB
+}
+
+func (p *primaryPlugin) Name() string                 { return primaryPluginName }
+func (p *primaryPlugin) Initialize(db *gorm.DB) error { return nil }
+
+// primaryDB retourne la connexion primaire de db, ou db lui-même s'il n'est
+// pas réparti entre primaire et réplicas
+func primaryDB(db *gorm.DB) *gorm.DB {
+	if plugin, ok := db.Config.Plugins[primaryPluginName].(*primaryPlugin); ok {
+		return plugin.db
+	}
+	return db
+}
+
+// splitReplicaHost sépare l'hôte et le port d'un réplica, defaultPort si le
+// port n'est pas précisé
+func splitReplicaHost(hostPort string, defaultPort int) (string, int, error) {
+	host, rawPort, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		// Sans port : l'hôte seul, éventuellement une adresse IPv6 entre crochets
+		host := strings.TrimSuffix(strings.TrimPrefix(hostPort, "["), "]")
+		if host == "" || strings.ContainsAny(host, "[]/ ") {
+			return "", 0, fmt.Errorf("réplica %q invalide (hôte ou hôte:port)", hostPort)
+		}
+		return host, defaultPort, nil
+	}
+
+	port, err := strconv.Atoi(rawPort)
+	if err != nil || host == "" || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("réplica %q invalide (hôte ou hôte:port)", hostPort)
+	}
+	return host, port, nil
+}
+
+// replica est le pool de connexions d'un réplica et son état
+type replica struct {
+	host    string
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// replicaSet répartit les lectures entre les réplicas disponibles. Il
+// implémente dbresolver.Policy : un réplica injoignable ou trop en retard est
+// écarté jusqu'à la vérification suivante, et les lectures reviennent au
+// primaire lorsqu'aucun réplica n'est disponible.
+type replicaSet struct {
+	driver   string
+	policy   string
+	maxLag   time.Duration
+	timeout  time.Duration
+	logger   logger.Interface
+	primary  gorm.ConnPool
+	replicas []*replica
+	byPool   map[gorm.ConnPool]*replica
+	next     atomic.Uint64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newReplicaSet ouvre le pool de connexions de chaque réplica de config
+func newReplicaSet(config *Config, primary gorm.ConnPool, log logger.Interface) (*replicaSet, error) {
+	set := &replicaSet{
+		driver:  config.driver(),
+		policy:  config.ReplicaPolicy,
+		maxLag:  config.ReplicaMaxLag,
+		timeout: config.ConnectTimeout,
+		logger:  log,
+		primary: primary,
+		byPool:  make(map[gorm.ConnPool]*replica),
+	}
+
+	maxIdle, maxOpen := config.ReplicaMaxIdleConns, config.ReplicaMaxOpenConns
+	if maxIdle == 0 {
+		maxIdle = config.MaxIdleConns
+	}
+	if maxOpen == 0 {
+		maxOpen = config.MaxOpenConns
+	}
+
+	for _, hostPort := range config.ReplicaHosts {
+		replicaConfig := *config
+		host, port, err := splitReplicaHost(hostPort, config.Port)
+		if err != nil {
+			set.close()
+			return nil, err
+		}
+		replicaConfig.Host, replicaConfig.Port = host, port
+
+		sqlDB, err := replicaConfig.openDB()
+		if err != nil {
+			set.close()
+			return nil, fmt.Errorf("réplica %s: %w", hostPort, err)
+		}
+		sqlDB.SetMaxIdleConns(maxIdle)
+		sqlDB.SetMaxOpenConns(maxOpen)
+		sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime)
+
+		r := &replica{host: hostPort, db: sqlDB}
+		set.replicas = append(set.replicas, r)
+		set.byPool[sqlDB] = r
+	}
+
+	return set, nil
+}
+
+// Resolve choisit le pool d'une lecture parmi les réplicas disponibles
+func (s *replicaSet) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	available := make([]*replica, 0, len(connPools))
+	for _, connPool := range connPools {
+		if r, ok := s.byPool[connPool]; ok && r.healthy.Load() {
+			available = append(available, r)
+		}
+	}
+	if len(available) == 0 {
+		return s.primary
+	}
+
+	switch s.policy {
+	case ReplicaPolicyRoundRobin:
+		return available[(s.next.Add(1)-1)%uint64(len(available))].db
+	case ReplicaPolicyLeastConn:
+		least := available[0]
+		for _, r := range available[1:] {
+			if r.db.Stats().InUse < least.db.Stats().InUse {
+				least = r
+			}
+		}
+		return least.db
+	default:
+		return available[rand.IntN(len(available))].db
+	}
+}
+
+// dialectors retourne un dialecte par réplica, suivi de celui du primaire.
+// Le primaire figure parmi les réplicas pour que dbresolver consulte Resolve
+// même avec un seul réplica, Resolve ne le choisissant qu'en dernier recours.
+func (s *replicaSet) dialectors(primary gorm.Dialector) []gorm.Dialector {
+	dialectors := make([]gorm.Dialector, 0, len(s.replicas)+1)
+	for _, r := range s.replicas {
+		dialectors = append(dialectors, pooledDialector{Dialector: primary, conn: r.db})
+	}
+	return append(dialectors, pooledDialector{Dialector: primary, conn: s.primary})
+}
+
+// check vérifie la disponibilité et le retard de chaque réplica
+func (s *replicaSet) check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, r := range s.replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.checkReplica(ctx, r)
+		}()
+	}
+	wg.Wait()
+}
+
+// checkReplica met à jour l'état d'un réplica et journalise ses changements
+func (s *replicaSet) checkReplica(ctx context.Context, r *replica) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	lag, err := replicaLag(ctx, s.driver, r.db)
+	if err == nil && s.maxLag > 0 && lag > s.maxLag {
+		err = fmt.Errorf("retard de réplication de %s (maximum %s)", lag.Round(time.Millisecond), s.maxLag)
+	}
+
+	healthy := err == nil
+	if r.healthy.Swap(healthy) == healthy {
+		return
+	}
+	if healthy {
+		s.logger.Info(ctx, "réplica %s disponible", r.host)
+	} else {
+		s.logger.Warn(ctx, "réplica %s écarté des lectures: %v", r.host, err)
+	}
+}
+
+// watch vérifie les réplicas toutes les replicaCheckInterval jusqu'à close
+func (s *replicaSet) watch() {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(replicaCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				s.check(context.Background())
+			}
+		}
+	}()
+}
+
+// close arrête les vérifications et ferme les pools des réplicas
+func (s *replicaSet) close() error {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+	}
+
+	var errs []error
+	for _, r := range s.replicas {
+		if err := r.db.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("réplica %s: %w", r.host, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// replicaLag retourne le retard de réplication d'un réplica. Le retard n'est
+// mesuré qu'avec PostgreSQL et MySQL : pour les autres bases, seule la
+// disponibilité du réplica est vérifiée.
+func replicaLag(ctx context.Context, driver string, db *sql.DB) (time.Duration, error) {
+	switch driver {
+	case DriverPostgres:
+		// Un réplica qui a rejoué tout le WAL reçu n'est pas en retard, même si
+		// sa dernière transaction rejouée est ancienne
+		var seconds float64
+		err := db.QueryRowContext(ctx, `SELECT CASE
+			WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+			ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+		END`).Scan(&seconds)
+		return time.Duration(seconds * float64(time.Second)), err
+	case DriverMySQL:
+		return mysqlReplicaLag(ctx, db)
+	default:
+		return 0, db.PingContext(ctx)
+	}
+}
+
+// mysqlReplicaLag lit Seconds_Behind_Source (MySQL 8.0.22+) ou
+// Seconds_Behind_Master (MariaDB) dans SHOW REPLICA STATUS
+func mysqlReplicaLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	rows, err := db.QueryContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	// Aucune ligne : le serveur n'est pas un réplica
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+
+	for i, column := range columns {
+		if column != "Seconds_Behind_Source" && column != "Seconds_Behind_Master" {
+			continue
+		}
+		if !values[i].Valid {
+			return 0, errors.New("réplication arrêtée")
+		}
+		seconds, err := strconv.Atoi(values[i].String)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, nil
+}
+
+// pooledDialector est le dialecte d'un pool déjà ouvert : dbresolver n'en
+// utilise que le pool, les requêtes étant construites par le dialecte du primaire
+type pooledDialector struct {
+	gorm.Dialector
+	conn gorm.ConnPool
+}
+
+func (d pooledDialector) Initialize(db *gorm.DB) error {
+	db.ConnPool = d.conn
+	return nil
+}
+
+// withReplicas retourne une connexion qui envoie les lectures aux réplicas de
+// config et les écritures à primary. primary reste utilisable seul et n'est
+// jamais redirigé vers un réplica.
+func withReplicas(config *Config, gormConfig *gorm.Config, primary *gorm.DB) (*gorm.DB, *replicaSet, error) {
+	sqlDB, err := primary.DB()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	set, err := newReplicaSet(config, sqlDB, gormConfig.Logger)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Une seconde instance GORM sur le même pool, pour que les callbacks de
+	// dbresolver ne s'appliquent pas à primary
+	db, err := gorm.Open(config.dialector(sqlDB), gormConfig)
+	if err != nil {
+		set.close()
+		return nil, nil, err
+	}
+
+	err = db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: set.dialectors(primary.Dialector),
+		Policy:   set,
+	}))
+	if err == nil {
+		err = db.Use(&primaryPlugin{db: primary})
+	}
+	if err != nil {
+		set.close()
+		return nil, nil, err
+	}
+
+	// Les réplicas injoignables au démarrage sont écartés dès la première lecture
+	set.check(context.Background())
+	set.watch()
+
+	return db, set, nil
+}